@@ -0,0 +1,240 @@
+package xlog
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"strconv"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+// AdminHandler is an http.Handler which lists the global logger and the
+// loggers created by GetLogger, and changes their levels at runtime.
+//
+// A GET request responds with a JSON array describing every logger, or with
+// a single logger when the "name" query parameter is given. A PUT or POST
+// request changes the logger named by the "name" query parameter, or the
+// global logger when the parameter is missing, using a JSON body such as:
+//
+//	{"level": "DEBUG", "enabled": true, "ttl": "15m"}
+//
+// Each property is optional. An empty level makes the logger inherit its
+// level, and a TTL reverts the changes once it has passed. A request for a
+// logger which does not exist responds with 404 Not Found, unless it's a PUT
+// or POST request with the "create" query parameter set to true, e.g.
+// "?name=app.db&create=1", which creates the logger with GetLogger.
+type AdminHandler struct {
+	// mu guards reverts.
+	mu sync.Mutex
+
+	// reverts holds the pending reverts by logger.
+	reverts map[*DefaultLogger]*adminRevert
+}
+
+// adminRevert holds the settings a logger reverts to once a TTL has passed.
+type adminRevert struct {
+	timer   *time.Timer
+	at      time.Time
+	level   Level
+	enabled bool
+}
+
+// adminLogger is the JSON description of a logger.
+type adminLogger struct {
+	Name             string        `json:"name"`
+	Root             bool          `json:"root"`
+	Level            string        `json:"level"`
+	LevelInherited   bool          `json:"level_inherited"`
+	Enabled          bool          `json:"enabled"`
+	Outputs          []adminOutput `json:"outputs"`
+	OutputsInherited bool          `json:"outputs_inherited"`
+	RevertAt         *time.Time    `json:"revert_at,omitempty"`
+}
+
+// adminOutput is the JSON description of an appended writer.
+type adminOutput struct {
+	Name  string `json:"name"`
+	Level string `json:"level"`
+}
+
+// adminChange is the JSON body of a request changing a logger.
+type adminChange struct {
+	Level   *string `json:"level"`
+	Enabled *bool   `json:"enabled"`
+	TTL     string  `json:"ttl"`
+}
+
+// outputLister is implemented by containers which can describe their outputs.
+type outputLister interface {
+	Outputs() []Output
+}
+
+// NewAdminHandler creates and returns a new *AdminHandler instance.
+func NewAdminHandler() *AdminHandler {
+	return &AdminHandler{reverts: make(map[*DefaultLogger]*adminRevert)}
+}
+
+// ServeHTTP implements http.Handler.ServeHTTP.
+func (h *AdminHandler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	switch r.Method {
+	case http.MethodGet, http.MethodHead:
+		if _, ok := r.URL.Query()["name"]; ok {
+			logger, ok := h.lookup(r, false)
+			if !ok {
+				h.notFound(w, r)
+				return
+			}
+			h.respond(w, http.StatusOK, h.describe(logger))
+			return
+		}
+		loggers := []adminLogger{h.describe(Instance())}
+		for _, logger := range Loggers() {
+			loggers = append(loggers, h.describe(logger))
+		}
+		h.respond(w, http.StatusOK, loggers)
+	case http.MethodPut, http.MethodPost:
+		var change adminChange
+		if err := json.NewDecoder(r.Body).Decode(&change); err != nil {
+			h.error(w, http.StatusBadRequest, fmt.Errorf("xlog: invalid request body: %v", err))
+			return
+		}
+		create, _ := strconv.ParseBool(r.URL.Query().Get("create"))
+		logger, ok := h.lookup(r, create)
+		if !ok {
+			h.notFound(w, r)
+			return
+		}
+		if err := h.change(logger, change); err != nil {
+			h.error(w, http.StatusBadRequest, err)
+			return
+		}
+		h.respond(w, http.StatusOK, h.describe(logger))
+	default:
+		w.Header().Set("Allow", "GET, HEAD, PUT, POST")
+		h.error(w, http.StatusMethodNotAllowed, fmt.Errorf("xlog: method %s not allowed", r.Method))
+	}
+}
+
+// lookup returns the logger named by the request, or the global logger. The
+// logger is created when it does not exist and create is true, and false is
+// returned when it does not exist otherwise.
+func (h *AdminHandler) lookup(r *http.Request, create bool) (*DefaultLogger, bool) {
+	name := r.URL.Query().Get("name")
+	if name == "" {
+		return Instance(), true
+	}
+	if create {
+		return GetLogger(name), true
+	}
+
+	return lookupLogger(name)
+}
+
+// notFound responds that the logger named by the request does not exist.
+func (h *AdminHandler) notFound(w http.ResponseWriter, r *http.Request) {
+	h.error(w, http.StatusNotFound, fmt.Errorf("xlog: logger %q not found", r.URL.Query().Get("name")))
+}
+
+// change applies the requested changes to the logger.
+func (h *AdminHandler) change(logger *DefaultLogger, change adminChange) error {
+	var level Level
+	if change.Level != nil && *change.Level != "" {
+		var err error
+		if level, err = parseLevel(*change.Level); err != nil {
+			return err
+		}
+	}
+	var ttl time.Duration
+	if change.TTL != "" {
+		var err error
+		if ttl, err = time.ParseDuration(change.TTL); err != nil || ttl <= 0 {
+			return fmt.Errorf("xlog: invalid ttl %q", change.TTL)
+		}
+	}
+
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	revert, pending := h.reverts[logger]
+	if pending {
+		revert.timer.Stop()
+		delete(h.reverts, logger)
+	} else {
+		revert = &adminRevert{
+			level:   Level(atomic.LoadInt32(&logger.Settings.level)),
+			enabled: logger.loadEnabled(),
+		}
+	}
+
+	if change.Level != nil {
+		logger.SetLevel(level)
+	}
+	if change.Enabled != nil {
+		logger.SetEnabled(*change.Enabled)
+	}
+	if ttl > 0 {
+		revert.at = time.Now().Add(ttl)
+		revert.timer = time.AfterFunc(ttl, func() {
+			h.revert(logger, revert)
+		})
+		h.reverts[logger] = revert
+	}
+
+	return nil
+}
+
+// revert restores the settings of the logger, unless the revert has been
+// replaced by a newer change.
+func (h *AdminHandler) revert(logger *DefaultLogger, revert *adminRevert) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	if h.reverts[logger] != revert {
+		return
+	}
+	delete(h.reverts, logger)
+	logger.SetLevel(revert.level)
+	logger.SetEnabled(revert.enabled)
+}
+
+// describe returns the JSON description of the logger.
+func (h *AdminHandler) describe(logger *DefaultLogger) adminLogger {
+	desc := adminLogger{
+		Name:           logger.Name,
+		Root:           logger == Instance(),
+		Level:          Levels[logger.Level()],
+		LevelInherited: atomic.LoadInt32(&logger.Settings.level) == 0,
+		Enabled:        logger.enabled(),
+		Outputs:        []adminOutput{},
+	}
+	desc.OutputsInherited = logger.Settings.loadContainer().Container == nil
+	container := logger.container()
+	if lister, ok := container.(outputLister); ok {
+		for _, output := range lister.Outputs() {
+			desc.Outputs = append(desc.Outputs, adminOutput{output.Name, levelString(output.Level)})
+		}
+	}
+
+	h.mu.Lock()
+	if revert, ok := h.reverts[logger]; ok {
+		at := revert.at
+		desc.RevertAt = &at
+	}
+	h.mu.Unlock()
+
+	return desc
+}
+
+// respond writes the value as a JSON response.
+func (h *AdminHandler) respond(w http.ResponseWriter, status int, value interface{}) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	enc := json.NewEncoder(w)
+	enc.SetIndent("", "  ")
+	enc.Encode(value)
+}
+
+// error writes the error as a JSON response.
+func (h *AdminHandler) error(w http.ResponseWriter, status int, err error) {
+	h.respond(w, status, map[string]string{"error": err.Error()})
+}
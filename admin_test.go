@@ -0,0 +1,93 @@
+package xlog
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+)
+
+// AdminRequest makes a request to the handler, and decodes the response.
+func AdminRequest(t *testing.T, h http.Handler, method, url, body string, v interface{}) int {
+	req := httptest.NewRequest(method, url, strings.NewReader(body))
+	rec := httptest.NewRecorder()
+	h.ServeHTTP(rec, req)
+	if v != nil {
+		if err := json.Unmarshal(rec.Body.Bytes(), v); err != nil {
+			t.Fatalf("Expected a JSON response but got '%s'.", rec.Body.String())
+		}
+	}
+
+	return rec.Code
+}
+
+// TestAdminHandler -
+func TestAdminHandler(t *testing.T) {
+	h := NewAdminHandler()
+	name := UniqueName("admin.db")
+	logger := GetLogger(name)
+	logger.AppendWriter(NewMemoryWriter(), WarningLevel)
+
+	var list []adminLogger
+	AdminRequest(t, h, "GET", "/", "", &list)
+	found := false
+	for _, desc := range list {
+		if desc.Name == name {
+			found = true
+			if len(desc.Outputs) != 1 || desc.Outputs[0].Level != "WARNING" || desc.OutputsInherited {
+				t.Errorf("Expected one WARNING output but got %+v.", desc.Outputs)
+			}
+		}
+	}
+	if !found || !list[0].Root {
+		t.Fatalf("Expected the root logger and %s to be listed, but got %+v.", name, list)
+	}
+
+	var desc adminLogger
+	code := AdminRequest(t, h, "PUT", "/?name="+name, `{"level": "error", "enabled": false}`, &desc)
+	if code != http.StatusOK || desc.Level != "ERROR" || desc.Enabled || logger.Level() != ErrorLevel {
+		t.Errorf("Expected the level to be changed but got %d %+v.", code, desc)
+	}
+
+	code = AdminRequest(t, h, "PUT", "/?name="+name, `{"level": "verbose"}`, nil)
+	if code != http.StatusBadRequest {
+		t.Errorf("Expected a bad request but got %d.", code)
+	}
+
+	AdminRequest(t, h, "POST", "/?name="+name, `{"level": "", "enabled": true}`, &desc)
+	if !desc.LevelInherited || desc.Level != Levels[Instance().Level()] {
+		t.Errorf("Expected the level to be inherited but got %+v.", desc)
+	}
+
+	AdminRequest(t, h, "PUT", "/?name="+name, `{"level": "DEBUG", "ttl": "20ms"}`, &desc)
+	if desc.RevertAt == nil || logger.Level() != DebugLevel {
+		t.Errorf("Expected a pending revert but got %+v.", desc)
+	}
+	time.Sleep(100 * time.Millisecond)
+	var reverted adminLogger
+	AdminRequest(t, h, "GET", "/?name="+name, "", &reverted)
+	if reverted.RevertAt != nil || !reverted.LevelInherited {
+		t.Errorf("Expected the level to be reverted but got %+v.", reverted)
+	}
+
+	missing := UniqueName("admin.missing")
+	code = AdminRequest(t, h, "GET", "/?name="+missing, "", nil)
+	if _, ok := lookupLogger(missing); code != http.StatusNotFound || ok {
+		t.Errorf("Expected not found without creating the logger but got %d.", code)
+	}
+	code = AdminRequest(t, h, "PUT", "/?name="+missing, `{"level": "debug"}`, nil)
+	if _, ok := lookupLogger(missing); code != http.StatusNotFound || ok {
+		t.Errorf("Expected not found without creating the logger but got %d.", code)
+	}
+	code = AdminRequest(t, h, "PUT", "/?name="+missing+"&create=1", `{"level": "debug"}`, &desc)
+	if created, ok := lookupLogger(missing); code != http.StatusOK || !ok || created.Level() != DebugLevel {
+		t.Errorf("Expected the logger to be created but got %d %+v.", code, desc)
+	}
+
+	code = AdminRequest(t, h, "DELETE", "/", "", nil)
+	if code != http.StatusMethodNotAllowed {
+		t.Errorf("Expected method not allowed but got %d.", code)
+	}
+}
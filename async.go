@@ -0,0 +1,345 @@
+package xlog
+
+import (
+	"context"
+	"io"
+	"log"
+	"sync"
+	"sync/atomic"
+)
+
+// DefaultAsyncQueueSize is the number of messages an AsyncContainer queues
+// when no size has been specified.
+const DefaultAsyncQueueSize = 1024
+
+// DropPolicy defines what an AsyncContainer does with a message when its
+// queue is full.
+type DropPolicy int
+
+const (
+	// BlockWhenFull blocks the logger until there is room in the queue.
+	BlockWhenFull DropPolicy = iota
+
+	// DropNewest discards the message being logged.
+	DropNewest
+
+	// DropOldest discards the oldest message in the queue to make room for the
+	// message being logged.
+	DropOldest
+)
+
+// AsyncContainer is an implementation of the Container interface which
+// queues messages, and writes them from a background goroutine, so slow
+// writers do not block the loggers. The number of queued messages is
+// bounded, and Policy decides what happens when the queue is full.
+type AsyncContainer struct {
+	// Capacity is the initial number of loggers to make.
+	Capacity int
+
+	// Policy defines what happens to messages when the queue is full.
+	Policy DropPolicy
+
+	// NeverDropLevel is the level at which messages are never dropped. Logging
+	// messages at this level or greater blocks until there is room in the
+	// queue, regardless of Policy. Messages at any level may be dropped when
+	// NeverDropLevel is zero.
+	NeverDropLevel Level
+
+	// size is the maximum number of queued messages.
+	size int
+
+	// dropped is the number of messages which have been dropped.
+	dropped uint64
+
+	// mu guards the fields below.
+	mu sync.Mutex
+
+	// notEmpty is signalled when a message is queued.
+	notEmpty *sync.Cond
+
+	// notFull is signalled when the queue has been emptied.
+	notFull *sync.Cond
+
+	// queue contains the messages waiting to be written.
+	queue []asyncEntry
+
+	// spare is the queue being written by the background goroutine.
+	spare []asyncEntry
+
+	// loggers are the loggers to be written to, stored as a levelLoggers map
+	// which is replaced rather than modified.
+	loggers atomic.Value
+
+	// entries are the entry writers to be written to, stored as a
+	// levelEntryWriters map which is replaced rather than modified.
+	entries atomic.Value
+
+	// pointers contains any files that have been opened for logging.
+	pointers []io.Closer
+
+	// outputs describes the appended writers.
+	outputs []Output
+
+	// closed defines whether the container has been closed.
+	closed bool
+
+	// closedFlag mirrors closed, and is accessed atomically so Closed does not
+	// take a lock.
+	closedFlag int32
+
+	// done is closed when the background goroutine exits.
+	done chan struct{}
+}
+
+// asyncEntry is a queued message, or a marker used by Flush.
+type asyncEntry struct {
+	writer  io.Writer
+	level   Level
+	data    []byte
+	entry   *Entry
+	flushed chan struct{}
+}
+
+// asyncWriter queues the messages written to it.
+type asyncWriter struct {
+	container *AsyncContainer
+	writer    io.Writer
+	level     Level
+}
+
+// asyncEntryWriter queues the entries written to it.
+type asyncEntryWriter struct {
+	*asyncWriter
+}
+
+// NewAsyncContainer creates and returns an *AsyncContainer instance which
+// queues up to size messages, and starts the goroutine writing them.
+func NewAsyncContainer(size int, policy DropPolicy) *AsyncContainer {
+	if size <= 0 {
+		size = DefaultAsyncQueueSize
+	}
+	c := &AsyncContainer{
+		Capacity:       DefaultInitialCapacity,
+		Policy:         policy,
+		NeverDropLevel: ErrorLevel,
+		size:           size,
+		queue:          make([]asyncEntry, 0, size),
+		spare:          make([]asyncEntry, 0, size),
+		pointers:       make([]io.Closer, 0, DefaultInitialCapacity),
+		done:           make(chan struct{}),
+	}
+	c.notEmpty = sync.NewCond(&c.mu)
+	c.notFull = sync.NewCond(&c.mu)
+	c.Clear()
+	go c.run()
+
+	return c
+}
+
+// Append adds a writer to the container at the given level.
+func (c *AsyncContainer) Append(writer io.Writer, level Level) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.outputs = append(c.outputs, newOutput(writer, level))
+	if _, ok := writer.(EntryWriter); ok {
+		entries, _ := c.entries.Load().(levelEntryWriters)
+		c.entries.Store(entries.with(level, func(lev Level) EntryWriter {
+			return asyncEntryWriter{&asyncWriter{c, writer, lev}}
+		}))
+		return
+	}
+
+	loggers := c.loggers.Load().(levelLoggers)
+	c.loggers.Store(loggers.with(level, func(lev Level) *log.Logger {
+		return newLogger(&asyncWriter{c, writer, lev})
+	}))
+}
+
+// AppendFile adds a file to the container at the given level. The file is
+// closed when the container is closed, after the queue has been written.
+func (c *AsyncContainer) AppendFile(file io.WriteCloser, level Level) {
+	c.mu.Lock()
+	c.pointers = append(c.pointers, file)
+	c.mu.Unlock()
+	c.Append(file, level)
+}
+
+// Get returns the loggers at the given level or higher. The returned slice
+// must not be modified.
+func (c *AsyncContainer) Get(level Level) []*log.Logger {
+	return c.loggers.Load().(levelLoggers)[level]
+}
+
+// Clear removes all the appended loggers. Messages which have already been
+// queued are still written.
+func (c *AsyncContainer) Clear() {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.loggers.Store(newLevelLoggers(c.Capacity))
+	c.entries.Store(levelEntryWriters(nil))
+	c.outputs = nil
+}
+
+// entryWriters returns the entry writers at the given level or higher. The
+// returned slice must not be modified.
+func (c *AsyncContainer) entryWriters(level Level) []EntryWriter {
+	entries, _ := c.entries.Load().(levelEntryWriters)
+	return entries[level]
+}
+
+// Outputs returns a description of each appended writer.
+func (c *AsyncContainer) Outputs() []Output {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return append([]Output(nil), c.outputs...)
+}
+
+// Dropped returns the number of messages which have been dropped because the
+// queue was full, or the container was closed.
+func (c *AsyncContainer) Dropped() uint64 {
+	return atomic.LoadUint64(&c.dropped)
+}
+
+// Flush waits until the messages queued before the call have been written,
+// or the context is done.
+func (c *AsyncContainer) Flush(ctx context.Context) error {
+	flushed := make(chan struct{})
+	c.mu.Lock()
+	if c.closed {
+		c.mu.Unlock()
+		return nil
+	}
+	c.queue = append(c.queue, asyncEntry{flushed: flushed})
+	c.notEmpty.Signal()
+	c.mu.Unlock()
+
+	select {
+	case <-flushed:
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
+
+// Close writes the queued messages, and then closes any files opened by the
+// container.
+func (c *AsyncContainer) Close() {
+	c.mu.Lock()
+	if c.closed {
+		c.mu.Unlock()
+		return
+	}
+	c.closed = true
+	atomic.StoreInt32(&c.closedFlag, 1)
+	c.notEmpty.Signal()
+	c.notFull.Broadcast()
+	c.mu.Unlock()
+
+	<-c.done
+	c.mu.Lock()
+	for _, pointer := range c.pointers {
+		pointer.Close()
+	}
+	c.pointers = nil
+	c.mu.Unlock()
+}
+
+// Closed returns whether the container has been closed.
+func (c *AsyncContainer) Closed() bool {
+	return atomic.LoadInt32(&c.closedFlag) == 1
+}
+
+// enqueue adds a message to the queue, applying the drop policy when the
+// queue is full.
+func (c *AsyncContainer) enqueue(entry asyncEntry) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	droppable := c.NeverDropLevel == 0 || entry.level < c.NeverDropLevel
+	for !c.closed && len(c.queue) >= c.size {
+		if droppable && c.Policy == DropNewest {
+			atomic.AddUint64(&c.dropped, 1)
+			return
+		}
+		if droppable && c.Policy == DropOldest && c.dropOldest() {
+			break
+		}
+		c.notFull.Wait()
+	}
+	if c.closed {
+		atomic.AddUint64(&c.dropped, 1)
+		return
+	}
+
+	c.queue = append(c.queue, entry)
+	c.notEmpty.Signal()
+}
+
+// dropOldest removes the oldest queued message which may be dropped, and
+// returns whether one was found. It must be called with c.mu held.
+func (c *AsyncContainer) dropOldest() bool {
+	for i, entry := range c.queue {
+		if entry.flushed == nil && (c.NeverDropLevel == 0 || entry.level < c.NeverDropLevel) {
+			copy(c.queue[i:], c.queue[i+1:])
+			c.queue[len(c.queue)-1] = asyncEntry{}
+			c.queue = c.queue[:len(c.queue)-1]
+			atomic.AddUint64(&c.dropped, 1)
+			return true
+		}
+	}
+
+	return false
+}
+
+// run writes the queued messages until the container is closed and the
+// queue is empty.
+func (c *AsyncContainer) run() {
+	defer close(c.done)
+	for {
+		c.mu.Lock()
+		for len(c.queue) == 0 && !c.closed {
+			c.notEmpty.Wait()
+		}
+		if len(c.queue) == 0 {
+			c.mu.Unlock()
+			return
+		}
+		batch := c.queue
+		c.queue = c.spare[:0]
+		c.notFull.Broadcast()
+		c.mu.Unlock()
+
+		for i, entry := range batch {
+			if entry.flushed != nil {
+				close(entry.flushed)
+			} else if entry.entry != nil {
+				entry.writer.(EntryWriter).WriteEntry(entry.entry)
+			} else if lw, ok := entry.writer.(LevelWriter); ok {
+				lw.WriteLevel(entry.level, entry.data)
+			} else {
+				entry.writer.Write(entry.data)
+			}
+			batch[i] = asyncEntry{}
+		}
+
+		c.mu.Lock()
+		c.spare = batch[:0]
+		c.mu.Unlock()
+	}
+}
+
+// Write implements io.Writer.Write by queueing a copy of p.
+func (w *asyncWriter) Write(p []byte) (int, error) {
+	data := make([]byte, len(p))
+	copy(data, p)
+	w.container.enqueue(asyncEntry{writer: w.writer, level: w.level, data: data})
+
+	return len(p), nil
+}
+
+// WriteEntry implements EntryWriter.WriteEntry by queueing the entry.
+func (w asyncEntryWriter) WriteEntry(entry *Entry) error {
+	w.container.enqueue(asyncEntry{writer: w.writer, level: w.level, entry: entry})
+
+	return nil
+}
@@ -0,0 +1,155 @@
+package xlog
+
+import (
+	"context"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+)
+
+// BlockingWriter records the writes made to it, blocking each write until
+// it's released.
+type BlockingWriter struct {
+	mu      sync.Mutex
+	lines   []string
+	release chan struct{}
+}
+
+func NewBlockingWriter() *BlockingWriter {
+	return &BlockingWriter{release: make(chan struct{})}
+}
+
+func (w *BlockingWriter) Write(p []byte) (int, error) {
+	<-w.release
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	w.lines = append(w.lines, strings.TrimSpace(string(p)))
+	return len(p), nil
+}
+
+func (w *BlockingWriter) Lines() string {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	return strings.Join(w.lines, ",")
+}
+
+// AsyncFixture creates a logger using an *AsyncContainer with a queue of two
+// messages, and logs the message "0", which blocks the background goroutine
+// until the writer is released.
+func AsyncFixture(policy DropPolicy) (*DefaultLogger, *AsyncContainer, *BlockingWriter) {
+	container := NewAsyncContainer(2, policy)
+	writer := NewBlockingWriter()
+	logger := New(LoggerName)
+	logger.Container = container
+	logger.Formatter = NewDefaultFormatter("{message}", DefaultDateFormat)
+	logger.AppendWriter(writer, DebugLevel)
+
+	logger.Info("0")
+	for {
+		container.mu.Lock()
+		empty := len(container.queue) == 0
+		container.mu.Unlock()
+		if empty {
+			break
+		}
+		time.Sleep(time.Millisecond)
+	}
+
+	return logger, container, writer
+}
+
+// TestAsyncDropNewest -
+func TestAsyncDropNewest(t *testing.T) {
+	logger, container, writer := AsyncFixture(DropNewest)
+	logger.Info("1")
+	logger.Info("2")
+	logger.Info("3")
+	close(writer.release)
+	container.Close()
+
+	ActualEquals(t, writer.Lines(), "0,1,2")
+	if container.Dropped() != 1 {
+		t.Errorf("Expected 1 dropped message but got %d.", container.Dropped())
+	}
+}
+
+// TestAsyncDropOldest -
+func TestAsyncDropOldest(t *testing.T) {
+	logger, container, writer := AsyncFixture(DropOldest)
+	logger.Error("1")
+	logger.Info("2")
+	logger.Info("3")
+	logger.Info("4")
+	close(writer.release)
+	container.Close()
+
+	ActualEquals(t, writer.Lines(), "0,1,4")
+	if container.Dropped() != 2 {
+		t.Errorf("Expected 2 dropped messages but got %d.", container.Dropped())
+	}
+}
+
+// TestAsyncNeverDrop -
+func TestAsyncNeverDrop(t *testing.T) {
+	logger, container, writer := AsyncFixture(DropNewest)
+	logger.Info("1")
+	logger.Info("2")
+
+	done := make(chan struct{})
+	go func() {
+		logger.Error("3")
+		close(done)
+	}()
+	select {
+	case <-done:
+		t.Fatal("Expected logging at ErrorLevel to block.")
+	case <-time.After(10 * time.Millisecond):
+	}
+	close(writer.release)
+	<-done
+	container.Close()
+
+	ActualEquals(t, writer.Lines(), "0,1,2,3")
+}
+
+// TestAsyncFlush -
+func TestAsyncFlush(t *testing.T) {
+	logger, container, writer := AsyncFixture(BlockWhenFull)
+	logger.Info("1")
+
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Millisecond)
+	defer cancel()
+	if err := container.Flush(ctx); err != context.DeadlineExceeded {
+		t.Errorf("Expected a deadline error but got %v.", err)
+	}
+
+	close(writer.release)
+	if err := container.Flush(context.Background()); err != nil {
+		t.Error(err)
+	}
+	ActualEquals(t, writer.Lines(), "0,1")
+
+	logger.Close()
+	if !container.Closed() {
+		t.Error("Expected the container to be closed.")
+	}
+}
+
+// TestAsyncPanicOn -
+func TestAsyncPanicOn(t *testing.T) {
+	logger, container, writer := AsyncFixture(BlockWhenFull)
+	defer container.Close()
+	logger.PanicOn = ErrorLevel
+	close(writer.release)
+
+	func() {
+		defer func() {
+			if recover() == nil {
+				t.Error("Expected logging at ErrorLevel to panic.")
+			}
+		}()
+		logger.Error("1")
+	}()
+	ActualEquals(t, writer.Lines(), "0,1")
+}
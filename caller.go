@@ -0,0 +1,106 @@
+package xlog
+
+import (
+	"bytes"
+	"fmt"
+	"path"
+	"runtime"
+	"strconv"
+	"strings"
+)
+
+// maxCallerDepth is the maximum number of frames searched for the caller.
+const maxCallerDepth = 32
+
+// packageDir is the directory of the source files of the package, which is
+// used to skip the frames of the package when looking up the caller.
+var packageDir = func() string {
+	_, file, _, _ := runtime.Caller(0)
+	return path.Dir(file)
+}()
+
+// Caller describes the code which logged a message.
+type Caller struct {
+	// File is the full path of the source file.
+	File string
+
+	// Line is the line number in the source file.
+	Line int
+
+	// Func is the name of the function, including its package path.
+	Func string
+}
+
+// ShortFile returns the base name of the source file.
+func (c *Caller) ShortFile() string {
+	return path.Base(c.File)
+}
+
+// ShortFunc returns the name of the function without its package path, e.g.
+// "main.(*Server).Serve".
+func (c *Caller) ShortFunc() string {
+	return c.Func[strings.LastIndexByte(c.Func, '/')+1:]
+}
+
+// String returns the base name of the file and the line, e.g. "main.go:42".
+func (c *Caller) String() string {
+	return c.ShortFile() + ":" + strconv.Itoa(c.Line)
+}
+
+// lookupCaller returns the first caller outside of the package, after
+// skipping another skip frames. Nil is returned when the caller is not found.
+func lookupCaller(skip int) *Caller {
+	var pcs [maxCallerDepth]uintptr
+	frames := runtime.CallersFrames(pcs[:runtime.Callers(2, pcs[:])])
+	outside := false
+	for {
+		frame, more := frames.Next()
+		if outside || !isPackageFrame(frame) {
+			outside = true
+			if skip <= 0 {
+				return &Caller{File: frame.File, Line: frame.Line, Func: frame.Function}
+			}
+			skip--
+		}
+		if !more {
+			return nil
+		}
+	}
+}
+
+// captureStack returns at most depth frames of the stack, starting at the first
+// caller outside of the package, after skipping another skip frames. The
+// frames of the runtime are omitted. Each frame is written as the function,
+// and the file and line indented by a tab on the next line.
+func captureStack(skip, depth int) string {
+	pcs := make([]uintptr, maxCallerDepth+skip+depth)
+	frames := runtime.CallersFrames(pcs[:runtime.Callers(2, pcs)])
+	buf := &bytes.Buffer{}
+	outside := false
+	for depth > 0 {
+		frame, more := frames.Next()
+		if outside || !isPackageFrame(frame) {
+			outside = true
+			if skip > 0 {
+				skip--
+			} else if !strings.HasPrefix(frame.Function, "runtime.") {
+				if buf.Len() > 0 {
+					buf.WriteByte('\n')
+				}
+				fmt.Fprintf(buf, "%s\n\t%s:%d", frame.Function, frame.File, frame.Line)
+				depth--
+			}
+		}
+		if !more {
+			break
+		}
+	}
+
+	return buf.String()
+}
+
+// isPackageFrame returns whether the frame belongs to the source files of the
+// package, other than its tests.
+func isPackageFrame(frame runtime.Frame) bool {
+	return path.Dir(frame.File) == packageDir && !strings.HasSuffix(frame.File, "_test.go")
+}
@@ -0,0 +1,104 @@
+package xlog
+
+import (
+	"runtime"
+	"strconv"
+	"strings"
+	"testing"
+)
+
+// PreviousLine returns the line before the code calling it.
+func PreviousLine() string {
+	_, _, line, _ := runtime.Caller(1)
+	return strconv.Itoa(line - 1)
+}
+
+// CallerFunc returns the name of the function calling it, as rendered by the
+// {func} placeholder.
+func CallerFunc() string {
+	pc, _, _, _ := runtime.Caller(1)
+	return (&Caller{Func: runtime.FuncForPC(pc).Name()}).ShortFunc()
+}
+
+// WrapWarning wraps the logger, as the functions of an application would.
+func WrapWarning(logger Loggable, message string) {
+	logger.Warning(message)
+}
+
+// TestCaller -
+func TestCaller(t *testing.T) {
+	logger, writer := LoggerFixture(DebugLevel)
+	logger.Formatter = NewDefaultFormatter("{caller} {file}:{line} {func} {message}", DefaultDateFormat)
+	prefix := "caller_test.go:"
+	fn := CallerFunc()
+
+	logger.Info("Info.")
+	line := PreviousLine()
+	ActualEquals(t, writer.String(), prefix+line+" "+prefix+line+" "+fn+" Info.\n")
+
+	logger.Errorf("%s.", "Errorf")
+	line = PreviousLine()
+	ActualEquals(t, writer.String(), prefix+line+" "+prefix+line+" "+fn+" Errorf.\n")
+
+	child := logger.With("key", "value")
+	child.Writer(NoticeLevel).Write([]byte("Writer."))
+	line = PreviousLine()
+	ActualEquals(t, writer.String(), prefix+line+" "+prefix+line+" "+fn+" Writer.\n")
+
+	logger.SetCallerSkip(1)
+	WrapWarning(logger, "Wrapped.")
+	line = PreviousLine()
+	ActualEquals(t, writer.String(), prefix+line+" "+prefix+line+" "+fn+" Wrapped.\n")
+	logger.SetCallerSkip(0)
+
+	formatter := Instance().formatter()
+	SetFormatter(NewDefaultFormatter("{caller} {message}", DefaultDateFormat))
+	defer SetFormatter(formatter)
+	AppendWriter(writer, DebugLevel)
+	Alert("Global.")
+	line = PreviousLine()
+	ActualEquals(t, writer.String(), prefix+line+" Global.\n")
+}
+
+// TestCallerNotUsed -
+func TestCallerNotUsed(t *testing.T) {
+	f := NewDefaultFormatter("{name} {message}", DefaultDateFormat)
+	if f.UsesCaller() {
+		t.Error("Expected the format to not use the caller.")
+	}
+	f.SetFormat("{func} {message}")
+	if !f.UsesCaller() {
+		t.Error("Expected the format to use the caller.")
+	}
+	ActualEquals(t, f.Format(LoggerName, InfoLevel, nil, "Test."), " Test.")
+}
+
+// TestStack -
+func TestStack(t *testing.T) {
+	logger, writer := LoggerFixture(DebugLevel)
+	logger.Formatter = NewDefaultFormatter("{message}|{stack}", DefaultDateFormat)
+	pc, _, _, _ := runtime.Caller(0)
+	fn := runtime.FuncForPC(pc).Name()
+
+	logger.Error("Error.")
+	ActualEquals(t, writer.String(), "Error.|\n")
+
+	logger.Critical("Critical.")
+	line := PreviousLine()
+	ActualContains(t, writer.String(), "Critical.|"+fn+"\n\t")
+	ActualContains(t, writer.String(), "caller_test.go:"+line+"\n")
+	for _, trimmed := range []string{"logger.go", "runtime."} {
+		if strings.Contains(writer.String(), trimmed) {
+			t.Errorf("Expected '%s' to be trimmed from the stack.", trimmed)
+		}
+	}
+
+	logger.StackDepth = 1
+	logger.Alert("Alert.")
+	ActualEquals(t, strconv.Itoa(strings.Count(writer.String(), "\n")), "2")
+
+	logger.StackLevel = ErrorLevel
+	logger.Formatter = NewJSONFormatter(DefaultDateFormat)
+	logger.Error("Error.")
+	ActualContains(t, writer.String(), `"message":"Error.","stack":"`+fn+`\n\t`)
+}
@@ -0,0 +1,158 @@
+package xlog
+
+import (
+	"io"
+	"os"
+	"strings"
+)
+
+// DefaultColors maps levels to the SGR parameters of the ANSI escape codes
+// used to color them.
+var DefaultColors = map[Level]string{
+	DebugLevel:     "90",
+	InfoLevel:      "36",
+	NoticeLevel:    "32",
+	WarningLevel:   "33",
+	ErrorLevel:     "31",
+	CriticalLevel:  "1;31",
+	AlertLevel:     "1;35",
+	EmergencyLevel: "1;37;41",
+}
+
+// ColorWriter is an implementation of EntryWriter which colors the level in
+// each message, or the whole message, using ANSI escape codes. Messages are
+// formatted using the formatter of the logger, which colors the {level}
+// placeholders of a DefaultFormatter after their modifiers are applied.
+// Formatters which cannot color the level, such as the JSONFormatter, write
+// messages without colors unless Line is true. Messages are written unchanged
+// when colors are disabled, which NewColorWriter does when the writer is not
+// a terminal.
+//
+// The writer is safe for concurrent use when the wrapped writer is, but its
+// fields must be set before it's used.
+type ColorWriter struct {
+	// Writer is the wrapped writer.
+	Writer io.Writer
+
+	// Colors maps levels to the SGR parameters of their color, e.g. "1;31"
+	// for bold red. Levels which are not mapped are not colored.
+	Colors map[Level]string
+
+	// Line defines whether the whole message is colored, rather than the level.
+	Line bool
+
+	// Enabled defines whether messages are colored.
+	Enabled bool
+}
+
+// colorFormatter is implemented by formatters which color the level of the
+// messages they format.
+type colorFormatter interface {
+	formatColor(entry *Entry, color string) string
+}
+
+// NewColorWriter creates and returns a new *ColorWriter instance which colors
+// the levels using DefaultColors. Colors are enabled as decided by
+// ColorEnabled.
+func NewColorWriter(writer io.Writer) *ColorWriter {
+	return &ColorWriter{
+		Writer:  writer,
+		Colors:  DefaultColors,
+		Enabled: ColorEnabled(writer),
+	}
+}
+
+// Name returns the name of the wrapped writer.
+func (w *ColorWriter) Name() string {
+	return newOutput(w.Writer, 0).Name
+}
+
+// Write implements io.Writer.Write by writing the message without colors, as
+// its level is not known.
+func (w *ColorWriter) Write(p []byte) (int, error) {
+	return w.Writer.Write(p)
+}
+
+// WriteEntry implements EntryWriter.WriteEntry by formatting the entry, and
+// coloring its level, or the whole message when Line is true. Entries which
+// were not made by a logger are written as their message.
+func (w *ColorWriter) WriteEntry(entry *Entry) error {
+	code, ok := w.Colors[entry.Level]
+	cf, isColor := entry.formatter.(colorFormatter)
+	line := ""
+	if !w.Enabled || !ok {
+		line = formatEntry(entry)
+	} else if w.Line {
+		line = string(appendColor(nil, code, formatEntry(entry)))
+	} else if isColor {
+		line = cf.formatColor(entry, code)
+	} else {
+		line = formatEntry(entry)
+	}
+	if !strings.HasSuffix(line, "\n") {
+		line += "\n"
+	}
+	_, err := io.WriteString(w.Writer, line)
+
+	return err
+}
+
+// formatEntry returns the entry formatted using the formatter of the logger
+// which made it, or its message when there is none.
+func formatEntry(entry *Entry) string {
+	switch f := entry.formatter.(type) {
+	case nil:
+		return entry.Message
+	case EntryFormatter:
+		return f.FormatEntry(entry)
+	default:
+		return f.Format(entry.Name, entry.Level, entry.Fields, entry.Message)
+	}
+}
+
+// appendColor appends the value to buf, colored using the SGR parameters of
+// the code. Trailing newlines are appended after the escape code which resets
+// the color.
+func appendColor(buf []byte, code, value string) []byte {
+	trimmed := strings.TrimRight(value, "\n")
+	buf = append(buf, "\x1b["...)
+	buf = append(buf, code...)
+	buf = append(buf, 'm')
+	buf = append(buf, trimmed...)
+	buf = append(buf, "\x1b[0m"...)
+
+	return append(buf, value[len(trimmed):]...)
+}
+
+// ColorEnabled returns whether messages written to the writer should be
+// colored. Colors are disabled when the NO_COLOR environment variable is not
+// empty, and enabled when FORCE_COLOR is not empty, "0" or "false". Otherwise
+// colors are enabled when the writer is a terminal.
+func ColorEnabled(writer io.Writer) bool {
+	if os.Getenv("NO_COLOR") != "" {
+		return false
+	}
+	switch force := os.Getenv("FORCE_COLOR"); force {
+	case "":
+	case "0", "false":
+		return false
+	default:
+		return true
+	}
+
+	return isTerminal(writer)
+}
+
+// isTerminal returns whether the writer is a file which is a terminal.
+func isTerminal(writer io.Writer) bool {
+	file, ok := writer.(*os.File)
+	if !ok {
+		return false
+	}
+	info, err := file.Stat()
+	if err != nil {
+		return false
+	}
+
+	return info.Mode()&os.ModeCharDevice != 0
+}
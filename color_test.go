@@ -0,0 +1,107 @@
+package xlog
+
+import (
+	"bytes"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+// TestColorWriter -
+func TestColorWriter(t *testing.T) {
+	buf := &bytes.Buffer{}
+	w := NewColorWriter(buf)
+	w.Enabled = true
+	logger := New(LoggerName)
+	logger.Formatter = NewDefaultFormatter("{name}.{level} {message}", DefaultDateFormat)
+	logger.AppendWriter(w, DebugLevel)
+
+	logger.Warning("Test.")
+	ActualEquals(t, buf.String(), "testing.\x1b[33mWARNING\x1b[0m Test.\n")
+
+	buf.Reset()
+	w.Line = true
+	logger.Emergency("Test.")
+	ActualEquals(t, buf.String(), "\x1b[1;37;41mtesting.EMERGENCY Test.\x1b[0m\n")
+
+	buf.Reset()
+	w.Enabled = false
+	logger.Error("Test.")
+	ActualEquals(t, buf.String(), "testing.ERROR Test.\n")
+}
+
+// TestColorWriterLevel -
+func TestColorWriterLevel(t *testing.T) {
+	buf := &bytes.Buffer{}
+	w := NewColorWriter(buf)
+	w.Enabled = true
+	logger := New("INFO")
+	logger.Formatter = NewDefaultFormatter("{name} {level|lower|short} {message}", DefaultDateFormat)
+	logger.AppendWriter(w, DebugLevel)
+
+	logger.Info("INFO")
+	ActualEquals(t, buf.String(), "INFO \x1b[36minf\x1b[0m INFO\n")
+
+	buf.Reset()
+	logger.Formatter = NewJSONFormatter(DefaultDateFormat)
+	logger.Info("Test.")
+	ActualContains(t, buf.String(), `"message":"Test."`)
+	if strings.Contains(buf.String(), "\x1b[") {
+		t.Errorf("Expected JSON messages not to be colored, got %q.", buf.String())
+	}
+}
+
+// TestColorEnabled -
+func TestColorEnabled(t *testing.T) {
+	t.Setenv("NO_COLOR", "")
+	t.Setenv("FORCE_COLOR", "")
+	buf := &bytes.Buffer{}
+	if ColorEnabled(buf) {
+		t.Error("Expected colors to be disabled for a buffer.")
+	}
+
+	t.Setenv("FORCE_COLOR", "1")
+	if !ColorEnabled(buf) {
+		t.Error("Expected FORCE_COLOR to enable colors.")
+	}
+	t.Setenv("NO_COLOR", "1")
+	if ColorEnabled(buf) {
+		t.Error("Expected NO_COLOR to disable colors.")
+	}
+}
+
+// TestColorAppend -
+func TestColorAppend(t *testing.T) {
+	t.Setenv("NO_COLOR", "")
+	t.Setenv("FORCE_COLOR", "1")
+	name := filepath.Join(t.TempDir(), "colors.log")
+	alias := filepath.Join(t.TempDir(), "alias.log")
+	file, err := os.Create(alias)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer file.Close()
+	Aliases["colors"] = file
+	defer delete(Aliases, "colors")
+
+	logger := New(LoggerName)
+	logger.Settings.Color = true
+	logger.Formatter = NewDefaultFormatter("{level} {message}", DefaultDateFormat)
+	logger.Append(name, DebugLevel)
+	logger.Append("colors", DebugLevel)
+	logger.Info("Test.")
+	logger.Close()
+
+	data, err := ioutil.ReadFile(name)
+	if err != nil {
+		t.Fatal(err)
+	}
+	ActualEquals(t, string(data), "INFO Test.\n")
+	data, err = ioutil.ReadFile(alias)
+	if err != nil {
+		t.Fatal(err)
+	}
+	ActualEquals(t, string(data), "\x1b[36mINFO\x1b[0m Test.\n")
+}
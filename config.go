@@ -0,0 +1,770 @@
+package xlog
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+)
+
+// configMu serializes the configurations being applied.
+var configMu sync.Mutex
+
+// ConfigError describes an invalid configuration.
+type ConfigError struct {
+	// File is the name of the configuration file or environment variable, or
+	// empty when the configuration was not read from either.
+	File string
+
+	// Line is the line of the configuration the error was found on, or zero
+	// when the line is not known.
+	Line int
+
+	// Err is the underlying error.
+	Err error
+}
+
+// Error implements error.Error.
+func (e *ConfigError) Error() string {
+	var where string
+	switch {
+	case e.File != "" && e.Line > 0:
+		where = fmt.Sprintf("%s:%d: ", e.File, e.Line)
+	case e.File != "":
+		where = e.File + ": "
+	case e.Line > 0:
+		where = fmt.Sprintf("line %d: ", e.Line)
+	}
+
+	return "xlog: " + where + e.Err.Error()
+}
+
+// Unwrap returns the underlying error.
+func (e *ConfigError) Unwrap() error {
+	return e.Err
+}
+
+// config is a parsed configuration.
+type config struct {
+	// root configures the global logger.
+	root *loggerConfig
+
+	// loggers configures the loggers returned by GetLogger, by name.
+	loggers map[string]*loggerConfig
+
+	// partial defines whether the settings which are not configured are left
+	// unchanged, rather than inherited.
+	partial bool
+}
+
+// loggerConfig is the configuration of a single logger. Settings which are
+// not configured are inherited, or left unchanged for the global logger.
+type loggerConfig struct {
+	line       int
+	level      Level
+	enabled    *bool
+	formatter  string
+	format     string
+	dateFormat string
+	outputs    []outputConfig
+	hasOutputs bool
+	fatalOn    *Level
+	panicOn    *Level
+	fileMode   os.FileMode
+}
+
+// outputConfig is the configuration of an output.
+type outputConfig struct {
+	line  int
+	path  string
+	level Level
+}
+
+// LoadConfig reads the configuration file at the given path, and applies it
+// to the global logger and the loggers returned by GetLogger. The format of
+// the file is chosen by its extension, ".json", ".yaml" or ".yml", and is
+// detected from the contents otherwise.
+//
+// A configuration looks like:
+//
+//	level: info
+//	format: "{date} {name}.{level} {message}"
+//	outputs:
+//	  - path: stdout
+//	  - path: /var/log/app.log
+//	    level: warning
+//	loggers:
+//	  app.db:
+//	    level: debug
+//	    formatter: json
+//	    file_mode: "0640"
+//	    outputs:
+//	      - path: /var/log/db.log
+//
+// The top-level settings configure the global logger, and each entry in
+// "loggers" configures the logger with that name. The settings are "level",
+// "enabled", "formatter" (default, json or logfmt), "format", "date_format",
+// "outputs" (each a "path", which may be one of the Aliases, and a "level"),
+// "fatal_on" and "panic_on" (lists of levels), and "file_mode" (an octal
+// string). A named logger inherits the settings which are not configured.
+//
+// The configuration is validated, and every output is opened, before any
+// logger is changed, so an invalid configuration leaves the loggers as they
+// were. Errors are returned as a *ConfigError.
+func LoadConfig(path string) error {
+	data, err := ioutil.ReadFile(path)
+	if err != nil {
+		return &ConfigError{File: path, Err: err}
+	}
+
+	cfg, err := parseConfig(data, configFormat(path))
+	if err == nil {
+		err = cfg.apply()
+	}
+	if e, ok := err.(*ConfigError); ok {
+		e.File = path
+	}
+
+	return err
+}
+
+// ConfigureFromReader reads a configuration from r and applies it in the
+// same way as LoadConfig. The format is either "json" or "yaml", or it's
+// detected from the contents when empty.
+func ConfigureFromReader(r io.Reader, format string) error {
+	data, err := ioutil.ReadAll(r)
+	if err != nil {
+		return &ConfigError{Err: err}
+	}
+	cfg, err := parseConfig(data, format)
+	if err != nil {
+		return err
+	}
+
+	return cfg.apply()
+}
+
+// configFormat returns the format of the file given by its extension, or
+// empty when the format should be detected from the contents.
+func configFormat(path string) string {
+	switch strings.ToLower(filepath.Ext(path)) {
+	case ".json":
+		return "json"
+	case ".yaml", ".yml":
+		return "yaml"
+	}
+
+	return ""
+}
+
+// parseConfig parses and validates the configuration.
+func parseConfig(data []byte, format string) (*config, error) {
+	if format == "" {
+		format = "yaml"
+		if trimmed := bytes.TrimSpace(data); len(trimmed) > 0 && trimmed[0] == '{' {
+			format = "json"
+		}
+	}
+
+	var node *configNode
+	var err error
+	switch strings.ToLower(format) {
+	case "json":
+		node, err = parseJSONNode(data)
+	case "yaml", "yml":
+		node, err = parseYAMLNode(data)
+	default:
+		return nil, &ConfigError{Err: fmt.Errorf("unknown configuration format %q", format)}
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	return decodeConfig(node)
+}
+
+// decodeConfig converts the parsed document into a *config.
+func decodeConfig(node *configNode) (*config, error) {
+	cfg := &config{loggers: make(map[string]*loggerConfig)}
+	if node.kind == nullNode {
+		cfg.root = &loggerConfig{line: node.line}
+		return cfg, nil
+	}
+	if node.kind != mappingNode {
+		return nil, node.errorf("expected a mapping of settings")
+	}
+
+	var err error
+	if cfg.root, err = decodeLoggerConfig(node, true); err != nil {
+		return nil, err
+	}
+	if loggers := node.values["loggers"]; loggers != nil && loggers.kind != nullNode {
+		if loggers.kind != mappingNode {
+			return nil, loggers.errorf("expected a mapping of logger names to settings")
+		}
+		for _, name := range loggers.keys {
+			value := loggers.values[name]
+			if name == "" || strings.HasPrefix(name, ".") || strings.HasSuffix(name, ".") {
+				return nil, value.errorf("invalid logger name %q", name)
+			}
+			logger := &loggerConfig{line: value.line}
+			if value.kind != nullNode {
+				if value.kind != mappingNode {
+					return nil, value.errorf("expected a mapping of settings for logger %q", name)
+				}
+				if logger, err = decodeLoggerConfig(value, false); err != nil {
+					return nil, err
+				}
+			}
+			cfg.loggers[name] = logger
+		}
+	}
+
+	return cfg, nil
+}
+
+// decodeLoggerConfig converts a mapping of settings into a *loggerConfig. The
+// "loggers" key is only allowed for the global logger.
+func decodeLoggerConfig(node *configNode, root bool) (*loggerConfig, error) {
+	logger := &loggerConfig{line: node.line}
+	for _, key := range node.keys {
+		value := node.values[key]
+		var err error
+		switch key {
+		case "level":
+			logger.level, err = value.level()
+		case "enabled":
+			var enabled bool
+			enabled, err = value.bool()
+			logger.enabled = &enabled
+		case "formatter":
+			if logger.formatter, err = value.string(); err == nil {
+				logger.formatter = strings.ToLower(logger.formatter)
+				switch logger.formatter {
+				case "default", "json", "logfmt":
+				default:
+					err = value.errorf("unknown formatter %q, expected default, json or logfmt", logger.formatter)
+				}
+			}
+		case "format":
+			logger.format, err = value.string()
+		case "date_format":
+			logger.dateFormat, err = value.string()
+		case "outputs":
+			logger.hasOutputs = true
+			logger.outputs, err = decodeOutputs(value)
+		case "fatal_on":
+			var mask Level
+			mask, err = value.levelMask()
+			logger.fatalOn = &mask
+		case "panic_on":
+			var mask Level
+			mask, err = value.levelMask()
+			logger.panicOn = &mask
+		case "file_mode":
+			logger.fileMode, err = value.fileMode()
+		case "loggers":
+			if !root {
+				err = value.errorf("loggers may only be configured at the top level")
+			}
+		default:
+			err = value.errorf("unknown setting %q", key)
+		}
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	return logger, nil
+}
+
+// decodeOutputs converts a sequence of outputs into []outputConfig. Each
+// output is either a mapping with a path and level, or just the path.
+func decodeOutputs(node *configNode) ([]outputConfig, error) {
+	if node.kind == nullNode {
+		return nil, nil
+	}
+	if node.kind != sequenceNode {
+		return nil, node.errorf("expected a list of outputs")
+	}
+
+	outputs := make([]outputConfig, 0, len(node.items))
+	for _, item := range node.items {
+		output := outputConfig{line: item.line, level: DebugLevel}
+		switch item.kind {
+		case scalarNode:
+			output.path = item.value
+		case mappingNode:
+			for _, key := range item.keys {
+				value := item.values[key]
+				var err error
+				switch key {
+				case "path":
+					output.path, err = value.string()
+				case "level":
+					output.level, err = value.level()
+				default:
+					err = value.errorf("unknown output setting %q", key)
+				}
+				if err != nil {
+					return nil, err
+				}
+			}
+		default:
+			return nil, item.errorf("expected an output path, or a mapping with a path and level")
+		}
+		if output.path == "" {
+			return nil, item.errorf("output is missing a path")
+		}
+		outputs = append(outputs, output)
+	}
+
+	return outputs, nil
+}
+
+// configTarget is a logger being configured, and the settings built for it.
+type configTarget struct {
+	logger    *DefaultLogger
+	config    *loggerConfig
+	formatter Formatter
+	container Container
+}
+
+// apply opens the outputs of every logger, and then changes the loggers. The
+// loggers are not changed when an output cannot be opened.
+func (c *config) apply() error {
+	targets := []*configTarget{{logger: Instance(), config: c.root}}
+	names := make([]string, 0, len(c.loggers))
+	for name := range c.loggers {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	for _, name := range names {
+		targets = append(targets, &configTarget{logger: GetLogger(name), config: c.loggers[name]})
+	}
+
+	for _, target := range targets {
+		if err := target.build(c.partial); err != nil {
+			for _, built := range targets {
+				if built.container != nil {
+					built.container.Close()
+				}
+			}
+			return err
+		}
+	}
+	if targets[0].container != nil {
+		globalMu.Lock()
+		globalAppended = true
+		globalMu.Unlock()
+	}
+	var replaced []*containerRef
+	configMu.Lock()
+	for i, target := range targets {
+		if old := target.apply(i == 0, c.partial); old != nil {
+			replaced = append(replaced, old)
+		}
+	}
+	configMu.Unlock()
+	retireReplaced(replaced)
+
+	return nil
+}
+
+// retireReplaced retires the references to the replaced containers. The
+// containers which are no longer used by the global logger, or the loggers
+// returned by GetLogger, are closed once no message is being written to them.
+func retireReplaced(replaced []*containerRef) {
+	if len(replaced) == 0 {
+		return
+	}
+
+	loggers := append(Loggers(), Instance())
+	used := make(map[Container]bool)
+	for _, logger := range loggers {
+		if container := logger.Settings.loadContainer().Container; container != nil {
+			used[container] = true
+		}
+	}
+	for _, ref := range replaced {
+		close := ref.Container != nil && !used[ref.Container]
+		if close {
+			used[ref.Container] = true
+		}
+		ref.retire(close)
+	}
+}
+
+// build creates the formatter and container configured for the logger. A
+// partial configuration which doesn't name a formatter changes the formats of
+// the formatter in use instead, when the configuration is applied.
+func (t *configTarget) build(partial bool) error {
+	cfg := t.config
+	if cfg.formatter != "" || (!partial && (cfg.format != "" || cfg.dateFormat != "")) {
+		t.formatter = newConfigFormatter(cfg.formatter, cfg.format, cfg.dateFormat)
+	}
+	if !cfg.hasOutputs {
+		return nil
+	}
+
+	mode := t.logger.Settings.loadFileOpenMode()
+	if cfg.fileMode != 0 {
+		mode = cfg.fileMode
+	}
+	container := NewDefaultContainer(DefaultInitialCapacity)
+	t.container = container
+	for _, output := range cfg.outputs {
+		if w, ok := Aliases[output.path]; ok {
+			container.Append(t.logger.aliasWriter(w), output.level)
+			continue
+		}
+		file, err := t.logger.openFile(output.path, mode)
+		if err != nil {
+			return &ConfigError{Line: output.line, Err: err}
+		}
+		container.AppendFile(file, output.level)
+	}
+
+	return nil
+}
+
+// apply changes the settings of the logger, and returns the reference to the
+// container it replaced, if any. The global logger keeps its formatter and
+// container when they are not configured, while a named logger inherits them
+// from its parent. A partial configuration only changes the settings which
+// are configured, so the formats are changed without replacing the formatter.
+// It must be called with configMu held.
+func (t *configTarget) apply(root, partial bool) *containerRef {
+	logger, cfg := t.logger, t.config
+	if cfg.level != 0 || !partial {
+		logger.SetLevel(cfg.level)
+	}
+	if cfg.enabled != nil || !partial {
+		logger.SetEnabled(cfg.enabled == nil || *cfg.enabled)
+	}
+	if cfg.fatalOn != nil || !partial {
+		logger.SetFatalOn(configuredMask(cfg.fatalOn))
+	}
+	if cfg.panicOn != nil || !partial {
+		logger.SetPanicOn(configuredMask(cfg.panicOn))
+	}
+	if cfg.fileMode != 0 {
+		logger.Settings.mu.Lock()
+		logger.Settings.FileOpenMode = cfg.fileMode
+		logger.Settings.mu.Unlock()
+	}
+
+	formatter := t.formatter
+	if formatter == nil && partial && (cfg.format != "" || cfg.dateFormat != "") {
+		if current := logger.loadFormatter(); current != nil {
+			setFormats(current, cfg.format, cfg.dateFormat)
+		} else {
+			formatter = newConfigFormatter("", cfg.format, cfg.dateFormat)
+		}
+	}
+
+	keep := root || partial
+	if formatter != nil || !keep {
+		logger.SetFormatter(formatter)
+	}
+	if t.container == nil && keep {
+		return nil
+	}
+
+	return logger.swapContainer(t.container)
+}
+
+// dateFormatSetter is implemented by formatters which have a date format,
+// such as the DefaultFormatter.
+type dateFormatSetter interface {
+	SetDateFormat(dateFormat string)
+}
+
+// setFormats changes the formats of the formatter. The formats which are
+// empty are left unchanged.
+func setFormats(formatter Formatter, format, dateFormat string) {
+	if format != "" {
+		formatter.SetFormat(format)
+	}
+	if ds, ok := formatter.(dateFormatSetter); ok && dateFormat != "" {
+		ds.SetDateFormat(dateFormat)
+	}
+}
+
+// configuredMask returns the configured level mask, or zero when it's not
+// configured.
+func configuredMask(mask *Level) Level {
+	if mask == nil {
+		return 0
+	}
+
+	return *mask
+}
+
+// newConfigFormatter creates the formatter of the given type. The default
+// formats are used for the formats which are empty.
+func newConfigFormatter(kind, format, dateFormat string) Formatter {
+	if dateFormat == "" {
+		dateFormat = DefaultDateFormat
+	}
+	var formatter Formatter
+	switch kind {
+	case "json":
+		formatter = NewJSONFormatter(dateFormat)
+	case "logfmt":
+		formatter = NewLogfmtFormatter(dateFormat)
+	default:
+		if format == "" {
+			format = DefaultMessageFormat
+		}
+		return NewDefaultFormatter(format, dateFormat)
+	}
+	if format != "" {
+		formatter.SetFormat(format)
+	}
+
+	return formatter
+}
+
+// level returns the level named by the node.
+func (n *configNode) level() (Level, error) {
+	str, err := n.string()
+	if err != nil {
+		return 0, err
+	}
+	level, err := parseLevel(str)
+	if err != nil {
+		return 0, n.errorf("unknown level %q", str)
+	}
+
+	return level, nil
+}
+
+// levelMask returns the levels named by the node, which is either a list of
+// levels, or a string of levels separated by "|" or ",".
+func (n *configNode) levelMask() (Level, error) {
+	var mask Level
+	switch n.kind {
+	case nullNode:
+	case sequenceNode:
+		for _, item := range n.items {
+			level, err := item.level()
+			if err != nil {
+				return 0, err
+			}
+			mask |= level
+		}
+	case scalarNode:
+		for _, str := range strings.FieldsFunc(n.value, func(r rune) bool {
+			return r == '|' || r == ','
+		}) {
+			level, err := parseLevel(strings.TrimSpace(str))
+			if err != nil {
+				return 0, n.errorf("unknown level %q", strings.TrimSpace(str))
+			}
+			mask |= level
+		}
+	default:
+		return 0, n.errorf("expected a list of levels")
+	}
+
+	return mask, nil
+}
+
+// bool returns the boolean value of the node.
+func (n *configNode) bool() (bool, error) {
+	if n.kind == scalarNode && !n.quoted {
+		switch strings.ToLower(n.value) {
+		case "true", "yes", "on":
+			return true, nil
+		case "false", "no", "off":
+			return false, nil
+		}
+	}
+
+	return false, n.errorf("expected true or false")
+}
+
+// string returns the string value of the node. A null value is empty.
+func (n *configNode) string() (string, error) {
+	switch n.kind {
+	case scalarNode:
+		return n.value, nil
+	case nullNode:
+		return "", nil
+	}
+
+	return "", n.errorf("expected a string")
+}
+
+// fileMode returns the file mode given by the node as an octal number.
+func (n *configNode) fileMode() (os.FileMode, error) {
+	str, err := n.string()
+	if err != nil {
+		return 0, err
+	}
+	mode, err := strconv.ParseUint(strings.TrimPrefix(str, "0o"), 8, 32)
+	if err != nil || mode == 0 || mode > 0777 {
+		return 0, n.errorf("invalid file mode %q, expected an octal mode such as \"0640\"", str)
+	}
+
+	return os.FileMode(mode), nil
+}
+
+// errorf returns a *ConfigError for the line of the node.
+func (n *configNode) errorf(format string, v ...interface{}) error {
+	return &ConfigError{Line: n.line, Err: fmt.Errorf(format, v...)}
+}
+
+// configNodeKind is the type of a configNode.
+type configNodeKind int
+
+const (
+	nullNode configNodeKind = iota
+	scalarNode
+	mappingNode
+	sequenceNode
+)
+
+// configNode is a value in a parsed configuration document, which remembers
+// the line it was found on.
+type configNode struct {
+	line   int
+	kind   configNodeKind
+	value  string
+	quoted bool
+	keys   []string
+	values map[string]*configNode
+	items  []*configNode
+}
+
+// newMappingNode returns an empty mapping node.
+func newMappingNode(line int) *configNode {
+	return &configNode{line: line, kind: mappingNode, values: make(map[string]*configNode)}
+}
+
+// set adds a key to the mapping, returning an error when the key is a duplicate.
+func (n *configNode) set(key string, value *configNode) error {
+	if _, ok := n.values[key]; ok {
+		return value.errorf("duplicate key %q", key)
+	}
+	n.keys = append(n.keys, key)
+	n.values[key] = value
+
+	return nil
+}
+
+// parseJSONNode parses a JSON document.
+func parseJSONNode(data []byte) (*configNode, error) {
+	lines := newLineIndex(data)
+	dec := json.NewDecoder(bytes.NewReader(data))
+	dec.UseNumber()
+
+	var parse func() (*configNode, error)
+	token := func() (json.Token, int, error) {
+		tok, err := dec.Token()
+		line := lines.line(dec.InputOffset())
+		if err != nil {
+			if e, ok := err.(*json.SyntaxError); ok {
+				line = lines.line(e.Offset)
+			}
+			if err == io.EOF {
+				err = io.ErrUnexpectedEOF
+			}
+			return nil, line, &ConfigError{Line: line, Err: err}
+		}
+		return tok, line, nil
+	}
+	parse = func() (*configNode, error) {
+		tok, line, err := token()
+		if err != nil {
+			return nil, err
+		}
+		switch tok := tok.(type) {
+		case json.Delim:
+			if tok == '{' {
+				node := newMappingNode(line)
+				for dec.More() {
+					key, _, err := token()
+					if err != nil {
+						return nil, err
+					}
+					value, err := parse()
+					if err != nil {
+						return nil, err
+					}
+					if err := node.set(key.(string), value); err != nil {
+						return nil, err
+					}
+				}
+				_, _, err = token()
+				return node, err
+			}
+			node := &configNode{line: line, kind: sequenceNode}
+			for dec.More() {
+				item, err := parse()
+				if err != nil {
+					return nil, err
+				}
+				node.items = append(node.items, item)
+			}
+			_, _, err = token()
+			return node, err
+		case string:
+			return &configNode{line: line, kind: scalarNode, value: tok, quoted: true}, nil
+		case json.Number:
+			return &configNode{line: line, kind: scalarNode, value: tok.String()}, nil
+		case bool:
+			return &configNode{line: line, kind: scalarNode, value: strconv.FormatBool(tok)}, nil
+		}
+		return &configNode{line: line, kind: nullNode}, nil
+	}
+
+	if len(bytes.TrimSpace(data)) == 0 {
+		return &configNode{line: 1, kind: nullNode}, nil
+	}
+	node, err := parse()
+	if err != nil {
+		return nil, err
+	}
+	if _, err := dec.Token(); err != io.EOF {
+		return nil, &ConfigError{Line: lines.line(dec.InputOffset()), Err: fmt.Errorf("unexpected data after the top-level value")}
+	}
+
+	return node, nil
+}
+
+// lineIndex converts byte offsets into line numbers.
+type lineIndex []int64
+
+// newLineIndex returns the offsets of the start of each line in data.
+func newLineIndex(data []byte) lineIndex {
+	index := lineIndex{0}
+	for i, b := range data {
+		if b == '\n' {
+			index = append(index, int64(i+1))
+		}
+	}
+
+	return index
+}
+
+// line returns the line number of the byte before the offset, which is the
+// last byte of a token when the offset follows it.
+func (index lineIndex) line(offset int64) int {
+	line := sort.Search(len(index), func(i int) bool {
+		return index[i] >= offset
+	})
+	if line < 1 {
+		line = 1
+	}
+
+	return line
+}
@@ -0,0 +1,171 @@
+package xlog
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+// TestLoadConfig -
+func TestLoadConfig(t *testing.T) {
+	dir := t.TempDir()
+	root := filepath.Join(dir, "root.log")
+	db := filepath.Join(dir, "db.log")
+	name := filepath.Join(dir, "xlog.yaml")
+	data := `# Logging configuration.
+level: info
+format: "{name}.{level} {message}"
+outputs:
+  - path: ` + root + `
+    level: debug
+fatal_on: []
+panic_on: [emergency]
+loggers:
+  config.db:
+    level: warning   # Only warnings and above.
+    formatter: json
+    date_format: '2006'
+    file_mode: "0600"
+    outputs:
+      - ` + db + `
+  config.db.pool:
+    level: debug
+`
+	if err := ioutil.WriteFile(name, []byte(data), 0644); err != nil {
+		t.Fatal(err)
+	}
+	defer Close()
+	if err := LoadConfig(name); err != nil {
+		t.Fatal(err)
+	}
+
+	Debug("Not written.")
+	Info("Written to root.")
+	GetLogger("config").Info("Inherits the root.")
+	GetLogger("config.db").Info("Not written.")
+	GetLogger("config.db").Error("Written to db.")
+	GetLogger("config.db.pool").Debug("Inherits db.")
+
+	actual, _ := ioutil.ReadFile(root)
+	ActualEquals(t, string(actual), "xlog.INFO Written to root.\nconfig.INFO Inherits the root.\n")
+	actual, _ = ioutil.ReadFile(db)
+	ActualContains(t, string(actual), `"level":"ERROR","name":"config.db","message":"Written to db."}`)
+	ActualContains(t, string(actual), `"level":"DEBUG","name":"config.db.pool","message":"Inherits db."}`)
+	if info, err := os.Stat(db); err != nil || info.Mode().Perm() != 0600 {
+		t.Errorf("Expected the file to be created with mode 0600 but got %v.", info.Mode())
+	}
+	if Instance().PanicOn != EmergencyLevel {
+		t.Errorf("Expected PanicOn to be EmergencyLevel but got %d.", Instance().PanicOn)
+	}
+}
+
+// TestConfigureFromReader -
+func TestConfigureFromReader(t *testing.T) {
+	data := `{
+  "loggers": {
+    "config.reader": {
+      "level": "error",
+      "enabled": false
+    }
+  }
+}`
+	if err := ConfigureFromReader(strings.NewReader(data), ""); err != nil {
+		t.Fatal(err)
+	}
+	logger := GetLogger("config.reader")
+	if logger.Level() != ErrorLevel || logger.loadEnabled() {
+		t.Errorf("Expected the logger to be configured but got level %d.", logger.Level())
+	}
+}
+
+// TestConfigErrors -
+func TestConfigErrors(t *testing.T) {
+	tests := []struct {
+		format   string
+		data     string
+		expected string
+	}{
+		{"yaml", "level: verbose", `line 1: unknown level "verbose"`},
+		{"yaml", "level: info\nlevle: debug", `line 2: unknown setting "levle"`},
+		{"yaml", "loggers:\n  app:\n    outputs: stdout", `line 3: expected a list of outputs`},
+		{"yaml", "loggers:\n  app:\n    outputs:\n      - level: info", `line 4: output is missing a path`},
+		{"yaml", "format: {date} {message}", `line 1: flow mappings are not supported`},
+		{"yaml", "level: info\n  enabled: true", `line 2: unexpected indentation`},
+		{"yaml", "level: info\nlevel: debug", `line 2: duplicate key "level"`},
+		{"yaml", "file_mode: 0999", `line 1: invalid file mode "0999"`},
+		{"yaml", "formatter: xml", `line 1: unknown formatter "xml"`},
+		{"yaml", "loggers:\n  app:\n    loggers: {}", `line 3: loggers may only be configured at the top level`},
+		{"yaml", "level: info\nfatal_on: [, error]", `line 2: empty list item`},
+		{"yaml", "fatal_on: [error,,alert]", `line 1: empty list item`},
+		{"json", "{\n  \"enabled\": \"maybe\"\n}", `line 2: expected true or false`},
+		{"json", "{\n  \"level\": \"info\",\n}", `line 2: invalid character ','`},
+		{"json", "{\"outputs\": [{\"path\": \"/nonexistent/dir/app.log\"}]}", `line 1: open /nonexistent/dir/app.log`},
+	}
+	for _, test := range tests {
+		err := ConfigureFromReader(strings.NewReader(test.data), test.format)
+		if err == nil {
+			t.Errorf("Expected an error for %q.", test.data)
+			continue
+		}
+		ActualContains(t, err.Error(), test.expected)
+	}
+
+	err := LoadConfig(filepath.Join(t.TempDir(), "missing.yaml"))
+	if _, ok := err.(*ConfigError); !ok {
+		t.Errorf("Expected a *ConfigError but got %v.", err)
+	}
+}
+
+// TestConfigExitLevels -
+func TestConfigExitLevels(t *testing.T) {
+	name := UniqueName("config.exit")
+	output := filepath.Join(t.TempDir(), "exit.log")
+	configure := func(settings string) {
+		data := "loggers:\n  " + name + ":\n    outputs: [" + output + "]\n" + settings
+		if err := ConfigureFromReader(strings.NewReader(data), "yaml"); err != nil {
+			t.Fatal(err)
+		}
+	}
+	configure("    panic_on: [emergency]\n")
+	logger := GetLogger(name)
+	defer logger.Close()
+
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		for i := 0; i < 100; i++ {
+			logger.Info("This is a test.")
+		}
+	}()
+	for i := 0; i < 20; i++ {
+		configure("    panic_on: [emergency]\n    fatal_on: [alert]\n")
+	}
+	<-done
+	if fatalOn, panicOn := logger.loadExitLevels(); fatalOn != AlertLevel || panicOn != EmergencyLevel {
+		t.Errorf("Expected the configured levels but got %d and %d.", fatalOn, panicOn)
+	}
+
+	configure("    level: info\n")
+	if fatalOn, panicOn := logger.loadExitLevels(); fatalOn != 0 || panicOn != 0 {
+		t.Errorf("Expected a reload to reset the levels but got %d and %d.", fatalOn, panicOn)
+	}
+}
+
+// TestConfigDateFormat -
+func TestConfigDateFormat(t *testing.T) {
+	name := UniqueName("config.date")
+	output := filepath.Join(t.TempDir(), "date.log")
+	data := "loggers:\n  " + name + ":\n    date_format: '2006'\n    outputs: [" + output + "]\n"
+	if err := ConfigureFromReader(strings.NewReader(data), "yaml"); err != nil {
+		t.Fatal(err)
+	}
+	logger := GetLogger(name)
+	logger.Info("This is a test.")
+	logger.Close()
+
+	actual, _ := ioutil.ReadFile(output)
+	ActualEquals(t, string(actual), time.Now().Format("2006")+" "+name+".INFO This is a test.\n")
+}
@@ -1,9 +1,11 @@
 package xlog
 
 import (
+	"fmt"
 	"io"
 	"log"
-	"os"
+	"sync"
+	"sync/atomic"
 )
 
 // Container is an interface that stores a container of log levels and loggers.
@@ -15,28 +17,64 @@ type Container interface {
 	Closed() bool
 }
 
-// DefaultContainer maps loggers to levels.
+// fileAppender is implemented by containers which close the files appended
+// to them when they are closed, such as the DefaultContainer.
+type fileAppender interface {
+	AppendFile(file io.WriteCloser, level Level)
+}
+
+// LevelWriter is implemented by writers which need the level of each message,
+// such as the SyslogWriter. Containers write messages to a LevelWriter using
+// WriteLevel rather than Write.
+type LevelWriter interface {
+	io.Writer
+	WriteLevel(level Level, p []byte) (int, error)
+}
+
+// Output describes a writer which has been appended to a container.
+type Output struct {
+	// Name is the file name of the writer, or its type when it has no name.
+	Name string
+
+	// Level is the level the writer was appended at.
+	Level Level
+}
+
+// DefaultContainer maps loggers to levels. DefaultContainer is safe for
+// concurrent use, and Get does not take a lock.
 type DefaultContainer struct {
 	// Capacity is the initial number of loggers to make.
 	Capacity int
 
-	// loggers are the loggers to be written to.
-	loggers map[Level][]*log.Logger
+	// mu guards changes to the loggers and pointers.
+	mu sync.Mutex
+
+	// loggers are the loggers to be written to, stored as a levelLoggers map
+	// which is replaced rather than modified.
+	loggers atomic.Value
+
+	// entries are the entry writers to be written to, stored as a
+	// levelEntryWriters map which is replaced rather than modified.
+	entries atomic.Value
 
 	// pointers contains any files that have been opened for logging.
-	pointers []*os.File
+	pointers []io.Closer
 
-	// closed defines whether the logger has been closed.
-	closed bool
+	// outputs describes the appended writers.
+	outputs []Output
+
+	// closed defines whether the logger has been closed. It's accessed atomically.
+	closed int32
 }
 
+// levelLoggers maps levels to the loggers written at that level.
+type levelLoggers map[Level][]*log.Logger
+
 // NewDefaultContainer creates and returns a *DefaultLoggerContainer instance.
 func NewDefaultContainer(capacity int) *DefaultContainer {
 	lm := &DefaultContainer{
 		Capacity: capacity,
-		loggers:  nil,
-		pointers: make([]*os.File, 0, DefaultInitialCapacity),
-		closed:   false,
+		pointers: make([]io.Closer, 0, DefaultInitialCapacity),
 	}
 	lm.Clear()
 
@@ -45,41 +83,136 @@ func NewDefaultContainer(capacity int) *DefaultContainer {
 
 // Append adds a logger to the container at the given level.
 func (m *DefaultContainer) Append(writer io.Writer, level Level) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.outputs = append(m.outputs, newOutput(writer, level))
+	if ew, ok := writer.(EntryWriter); ok {
+		entries, _ := m.entries.Load().(levelEntryWriters)
+		m.entries.Store(entries.with(level, func(Level) EntryWriter {
+			return ew
+		}))
+		return
+	}
+
 	logger := newLogger(writer)
-	for lev := range m.loggers {
-		if (lev&level > 0) || (lev >= level) {
-			m.loggers[lev] = append(m.loggers[lev], logger)
+	fn := func(Level) *log.Logger {
+		return logger
+	}
+	if lw, ok := writer.(LevelWriter); ok {
+		fn = func(lev Level) *log.Logger {
+			return newLogger(&levelWriter{lw, lev})
 		}
 	}
+	m.loggers.Store(m.load().with(level, fn))
+}
+
+// AppendFile adds a file to the container at the given level. Unlike writers
+// added with Append, the file is closed when the container is closed.
+func (m *DefaultContainer) AppendFile(file io.WriteCloser, level Level) {
+	m.mu.Lock()
+	m.pointers = append(m.pointers, file)
+	m.mu.Unlock()
+	m.Append(file, level)
 }
 
-// Get returns the loggers at the given level or higher.
+// Get returns the loggers at the given level or higher. The returned slice
+// must not be modified.
 func (m *DefaultContainer) Get(level Level) []*log.Logger {
-	return m.loggers[level]
+	return m.load()[level]
 }
 
 // Clear removes all the appended loggers.
 func (m *DefaultContainer) Clear() {
-	m.loggers = make(map[Level][]*log.Logger, len(Levels))
-	for level := range Levels {
-		m.loggers[level] = make([]*log.Logger, 0, m.Capacity)
-	}
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.loggers.Store(newLevelLoggers(m.Capacity))
+	m.entries.Store(levelEntryWriters(nil))
+	m.outputs = nil
+}
+
+// Outputs returns a description of each appended writer.
+func (m *DefaultContainer) Outputs() []Output {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	return append([]Output(nil), m.outputs...)
 }
 
 // Close closes any resources being used by the container.
 func (m *DefaultContainer) Close() {
-	if !m.closed {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	if atomic.CompareAndSwapInt32(&m.closed, 0, 1) {
 		for _, pointer := range m.pointers {
 			pointer.Close()
 		}
 		m.pointers = nil
-		m.closed = true
 	}
 }
 
 // Closed returns whether the container has been closed.
 func (m *DefaultContainer) Closed() bool {
-	return m.closed
+	return atomic.LoadInt32(&m.closed) == 1
+}
+
+// entryWriters returns the entry writers at the given level or higher. The
+// returned slice must not be modified.
+func (m *DefaultContainer) entryWriters(level Level) []EntryWriter {
+	entries, _ := m.entries.Load().(levelEntryWriters)
+	return entries[level]
+}
+
+// load returns the current loggers.
+func (m *DefaultContainer) load() levelLoggers {
+	return m.loggers.Load().(levelLoggers)
+}
+
+// newOutput returns an Output describing the writer.
+func newOutput(writer io.Writer, level Level) Output {
+	if named, ok := writer.(interface {
+		Name() string
+	}); ok {
+		return Output{named.Name(), level}
+	}
+
+	return Output{fmt.Sprintf("%T", writer), level}
+}
+
+// newLevelLoggers returns a levelLoggers map with an empty slice for each level.
+func newLevelLoggers(capacity int) levelLoggers {
+	loggers := make(levelLoggers, len(Levels))
+	for level := range Levels {
+		loggers[level] = make([]*log.Logger, 0, capacity)
+	}
+
+	return loggers
+}
+
+// with returns a copy of the map where a logger, which is returned by fn, is
+// appended to each level matching level.
+func (ll levelLoggers) with(level Level, fn func(Level) *log.Logger) levelLoggers {
+	loggers := make(levelLoggers, len(ll))
+	for lev, current := range ll {
+		if (lev&level > 0) || (lev >= level) {
+			appended := make([]*log.Logger, len(current), len(current)+1)
+			copy(appended, current)
+			loggers[lev] = append(appended, fn(lev))
+		} else {
+			loggers[lev] = current
+		}
+	}
+
+	return loggers
+}
+
+// levelWriter writes to a LevelWriter at a fixed level.
+type levelWriter struct {
+	writer LevelWriter
+	level  Level
+}
+
+// Write implements io.Writer.Write.
+func (w *levelWriter) Write(p []byte) (int, error) {
+	return w.writer.WriteLevel(w.level, p)
 }
 
 // newLogger returns a *log.Logger instance configured with the default options.
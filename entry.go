@@ -0,0 +1,93 @@
+package xlog
+
+import (
+	"fmt"
+	"io"
+	"time"
+)
+
+// Entry describes a message being logged.
+type Entry struct {
+	// Time is the time the message was logged.
+	Time time.Time
+
+	// Level is the level of the message.
+	Level Level
+
+	// Name is the name of the logger.
+	Name string
+
+	// Message is the message, before it's formatted.
+	Message string
+
+	// Args are the arguments which were logged, which make up the message.
+	// For the methods taking a format, such as Logf, they are the arguments
+	// after the format. They must not be modified.
+	Args []interface{}
+
+	// Fields are the fields attached to the logger. They must not be modified.
+	Fields Fields
+
+	// Caller is the code which logged the message, or nil when the formatter
+	// doesn't render it.
+	Caller *Caller
+
+	// Stack is the stack of the code which logged the message, or empty when
+	// the level is below Settings.StackLevel, or the formatter doesn't render
+	// it.
+	Stack string
+
+	// formatter is the formatter of the logger, which writers such as the
+	// ColorWriter use to format the entry. It's nil when the entry was not
+	// made by a logger.
+	formatter Formatter
+}
+
+// argsEntry returns an *Entry instance for the arguments given to the Format
+// method of a formatter, logged now.
+func argsEntry(name string, level Level, fields Fields, v []interface{}) *Entry {
+	return &Entry{
+		Time:    time.Now(),
+		Level:   level,
+		Name:    name,
+		Message: fmt.Sprint(v...),
+		Args:    v,
+		Fields:  fields,
+	}
+}
+
+// EntryWriter is implemented by writers which need the parts of each message
+// rather than the formatted message, such as the JournalWriter. Containers
+// pass each message to an EntryWriter using WriteEntry rather than Write, and
+// the formatter of the logger is only used by writers which format the entry
+// themselves, such as the ColorWriter.
+type EntryWriter interface {
+	io.Writer
+	WriteEntry(entry *Entry) error
+}
+
+// entryContainer is implemented by containers which hold EntryWriters.
+type entryContainer interface {
+	entryWriters(level Level) []EntryWriter
+}
+
+// levelEntryWriters maps levels to the entry writers written at that level.
+type levelEntryWriters map[Level][]EntryWriter
+
+// with returns a copy of the map where the writer returned by fn is appended
+// to each level matching level.
+func (lw levelEntryWriters) with(level Level, fn func(Level) EntryWriter) levelEntryWriters {
+	writers := make(levelEntryWriters, len(Levels))
+	for lev := range Levels {
+		current := lw[lev]
+		if (lev&level > 0) || (lev >= level) {
+			appended := make([]EntryWriter, len(current), len(current)+1)
+			copy(appended, current)
+			writers[lev] = append(appended, fn(lev))
+		} else {
+			writers[lev] = current
+		}
+	}
+
+	return writers
+}
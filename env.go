@@ -0,0 +1,137 @@
+package xlog
+
+import (
+	"fmt"
+	"os"
+	"strings"
+)
+
+const (
+	// EnvLevel is the environment variable holding the level of the global logger.
+	EnvLevel = "XLOG_LEVEL"
+
+	// EnvLevels is the environment variable holding the levels of named loggers.
+	EnvLevels = "XLOG_LEVELS"
+
+	// EnvFormatter is the environment variable holding the type of formatter.
+	EnvFormatter = "XLOG_FORMATTER"
+
+	// EnvFormat is the environment variable holding the message format.
+	EnvFormat = "XLOG_FORMAT"
+
+	// EnvDateFormat is the environment variable holding the date format.
+	EnvDateFormat = "XLOG_DATE_FORMAT"
+
+	// EnvOutput is the environment variable holding the outputs of the global logger.
+	EnvOutput = "XLOG_OUTPUT"
+)
+
+// ConfigureFromEnv configures the global logger and the loggers returned by
+// GetLogger from environment variables:
+//
+//	XLOG_LEVEL        the level of the global logger, e.g. "info".
+//	XLOG_LEVELS       comma-separated levels of named loggers, e.g.
+//	                  "db=debug,http=warning". An entry without a name sets
+//	                  the level of the global logger.
+//	XLOG_FORMATTER    the formatter of the global logger: default, json or logfmt.
+//	XLOG_FORMAT       the message format of the global logger.
+//	XLOG_DATE_FORMAT  the date format of the global logger.
+//	XLOG_OUTPUT       comma-separated file paths or Aliases the global logger
+//	                  writes to at DebugLevel, replacing its outputs.
+//
+// Only the settings given by the variables which are set are changed. Invalid
+// values are returned as a *ConfigError naming the variable, and the loggers
+// are not changed.
+func ConfigureFromEnv() error {
+	cfg, err := parseEnvConfig(os.LookupEnv)
+	if err != nil {
+		return err
+	}
+
+	err = cfg.apply()
+	if e, ok := err.(*ConfigError); ok && e.File == "" {
+		// Only the outputs are opened when the configuration is applied.
+		e.File = EnvOutput
+	}
+
+	return err
+}
+
+// parseEnvConfig reads a partial configuration using the lookup function.
+func parseEnvConfig(lookup func(string) (string, bool)) (*config, error) {
+	cfg := &config{
+		root:    &loggerConfig{},
+		loggers: make(map[string]*loggerConfig),
+		partial: true,
+	}
+	errorf := func(name, format string, v ...interface{}) error {
+		return &ConfigError{File: name, Err: fmt.Errorf(format, v...)}
+	}
+
+	if value, ok := lookup(EnvLevel); ok && value != "" {
+		level, err := parseLevel(strings.TrimSpace(value))
+		if err != nil {
+			return nil, errorf(EnvLevel, "unknown level %q", value)
+		}
+		cfg.root.level = level
+	}
+
+	if value, ok := lookup(EnvLevels); ok && value != "" {
+		for _, entry := range strings.Split(value, ",") {
+			entry = strings.TrimSpace(entry)
+			if entry == "" {
+				continue
+			}
+			name, str := "", entry
+			if i := strings.Index(entry, "="); i >= 0 {
+				name, str = strings.TrimSpace(entry[:i]), strings.TrimSpace(entry[i+1:])
+				if name == "" || strings.HasPrefix(name, ".") || strings.HasSuffix(name, ".") {
+					return nil, errorf(EnvLevels, "invalid logger name %q", name)
+				}
+			}
+			level, err := parseLevel(str)
+			if err != nil {
+				return nil, errorf(EnvLevels, "unknown level %q", str)
+			}
+
+			if name == "" {
+				if cfg.root.level != 0 {
+					return nil, errorf(EnvLevels, "the level of the global logger is already set")
+				}
+				cfg.root.level = level
+			} else if _, ok := cfg.loggers[name]; ok {
+				return nil, errorf(EnvLevels, "the level of %q is set more than once", name)
+			} else {
+				cfg.loggers[name] = &loggerConfig{level: level}
+			}
+		}
+	}
+
+	if value, ok := lookup(EnvFormatter); ok && value != "" {
+		cfg.root.formatter = strings.ToLower(strings.TrimSpace(value))
+		switch cfg.root.formatter {
+		case "default", "json", "logfmt":
+		default:
+			return nil, errorf(EnvFormatter, "unknown formatter %q, expected default, json or logfmt", value)
+		}
+	}
+	if value, ok := lookup(EnvFormat); ok {
+		cfg.root.format = value
+	}
+	if value, ok := lookup(EnvDateFormat); ok {
+		cfg.root.dateFormat = value
+	}
+
+	if value, ok := lookup(EnvOutput); ok && value != "" {
+		cfg.root.hasOutputs = true
+		for _, path := range strings.Split(value, ",") {
+			path = strings.TrimSpace(path)
+			if path == "" {
+				return nil, errorf(EnvOutput, "empty output path in %q", value)
+			}
+			cfg.root.outputs = append(cfg.root.outputs, outputConfig{path: path, level: DebugLevel})
+		}
+	}
+
+	return cfg, nil
+}
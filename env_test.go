@@ -0,0 +1,96 @@
+package xlog
+
+import (
+	"io/ioutil"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+// EnvLookup returns a lookup function reading from the map.
+func EnvLookup(env map[string]string) func(string) (string, bool) {
+	return func(name string) (string, bool) {
+		value, ok := env[name]
+		return value, ok
+	}
+}
+
+// TestConfigureFromEnv -
+func TestConfigureFromEnv(t *testing.T) {
+	name := filepath.Join(t.TempDir(), "env.log")
+	cfg, err := parseEnvConfig(EnvLookup(map[string]string{
+		EnvLevel:  "warning",
+		EnvLevels: "env.db=debug, env.http=ERROR",
+		EnvFormat: "{name}.{level} {message}",
+		EnvOutput: name,
+	}))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer Close()
+	http := GetLogger("env.http")
+	writer := NewMemoryWriter()
+	http.AppendWriter(writer, DebugLevel)
+	if err := cfg.apply(); err != nil {
+		t.Fatal(err)
+	}
+
+	Info("Not written.")
+	Warning("Written.")
+	GetLogger("env.db").Debug("Written.")
+	GetLogger("env").Info("Not written.")
+	http.Warning("Not written.")
+	http.Error("Written to the writer.")
+
+	actual, _ := ioutil.ReadFile(name)
+	ActualEquals(t, string(actual), "xlog.WARNING Written.\nenv.db.DEBUG Written.\n")
+	ActualEquals(t, writer.String(), "env.http.ERROR Written to the writer.\n")
+}
+
+// TestConfigureFromEnvDateFormat -
+func TestConfigureFromEnvDateFormat(t *testing.T) {
+	name := filepath.Join(t.TempDir(), "env.log")
+	cfg, err := parseEnvConfig(EnvLookup(map[string]string{
+		EnvDateFormat: "2006",
+		EnvOutput:     name,
+	}))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer Close()
+	formatter := NewDefaultFormatter("{date} {message}", DefaultDateFormat)
+	SetFormatter(formatter)
+	if err := cfg.apply(); err != nil {
+		t.Fatal(err)
+	}
+	if Instance().formatter() != formatter {
+		t.Error("Expected the formatter to be kept.")
+	}
+
+	Info("Written.")
+	actual, _ := ioutil.ReadFile(name)
+	ActualEquals(t, string(actual), time.Now().Format("2006")+" Written.\n")
+}
+
+// TestConfigureFromEnvErrors -
+func TestConfigureFromEnvErrors(t *testing.T) {
+	tests := []struct {
+		env      map[string]string
+		expected string
+	}{
+		{map[string]string{EnvLevel: "verbose"}, `xlog: XLOG_LEVEL: unknown level "verbose"`},
+		{map[string]string{EnvLevels: "db=debug,http=loud"}, `xlog: XLOG_LEVELS: unknown level "loud"`},
+		{map[string]string{EnvLevels: "=debug"}, `xlog: XLOG_LEVELS: invalid logger name ""`},
+		{map[string]string{EnvLevel: "info", EnvLevels: "debug"}, `xlog: XLOG_LEVELS: the level of the global logger is already set`},
+		{map[string]string{EnvFormatter: "xml"}, `xlog: XLOG_FORMATTER: unknown formatter "xml"`},
+		{map[string]string{EnvOutput: "stdout,,stderr"}, `xlog: XLOG_OUTPUT: empty output path`},
+	}
+	for _, test := range tests {
+		_, err := parseEnvConfig(EnvLookup(test.env))
+		if err == nil {
+			t.Errorf("Expected an error for %v.", test.env)
+			continue
+		}
+		ActualContains(t, err.Error(), test.expected)
+	}
+}
@@ -1,73 +1,480 @@
 package xlog
 
 import (
+	"bytes"
 	"fmt"
 	"regexp"
+	"sort"
+	"strconv"
 	"strings"
+	"sync"
+	"sync/atomic"
 	"time"
 )
 
+// dateRegexp matches date placeholders containing a date format.
+var dateRegexp = regexp.MustCompile(`{date\|([^}]+)}`)
+
+// dateLayouts maps the names of date presets to their layouts.
+var dateLayouts = map[string]string{
+	"rfc3339":     time.RFC3339,
+	"rfc3339nano": time.RFC3339Nano,
+}
+
 // Formatter is an interface that provides methods that format log messages.
 type Formatter interface {
 	SetFormat(format string)
 	PlaceholderFunc(key string, f func(string) string)
-	Format(name string, level Level, v ...interface{}) string
+	Format(name string, level Level, fields Fields, v ...interface{}) string
+}
+
+// EntryFormatter is implemented by formatters which format an Entry, and so
+// may render the parts of a message which are not passed to Format, such as
+// the caller. Loggers use FormatEntry rather than Format, and only look up
+// the caller when UsesCaller returns true, and capture the stack when
+// UsesStack returns true, as both are costly.
+type EntryFormatter interface {
+	Formatter
+	UsesCaller() bool
+	UsesStack() bool
+	FormatEntry(entry *Entry) string
 }
 
 // DefaultFormatter is the default implementation of the Formatter interface.
+// Besides the placeholders added with PlaceholderFunc, the format may contain
+// {date}, {level}, {name}, {message} and {fields}, and the placeholders
+// rendering the code which logged the message: {file}, {line}, {func}, and
+// {caller} which is the file and line, e.g. "main.go:42". The {stack}
+// placeholder renders the stack of messages at Settings.StackLevel or above.
+//
+// Each {date|layout} placeholder formats the date using its own layout, which
+// is either a Go time layout, or one of the presets "rfc3339", "rfc3339nano",
+// "unix" for seconds, or "unixms" for milliseconds since the Unix epoch. The
+// {date} placeholder uses the date format the formatter was created with,
+// which may also be a preset. Every placeholder renders the time of the
+// message, so they all show the same instant.
+//
+// Literal braces are written as "{{" and "}}", e.g. "{{{level}}}" renders
+// "{INFO}".
+//
+// The format is parsed when it's set, and each message is formatted in a
+// single pass, so placeholders in the values, such as a message containing
+// "{name}", are written as they are.
+//
+// DefaultFormatter is safe for concurrent use, and may be changed while
+// messages are being formatted.
 type DefaultFormatter struct {
-	messageFormat string
-	dateFormat    string
-	funcs         map[string]func(string) string
+	// mu serializes changes to the format.
+	mu sync.Mutex
+
+	// format stores the *defaultFormat being used.
+	format atomic.Value
+
+	// funcs provide replacements for custom placeholders.
+	funcs placeholderFuncs
+}
+
+// defaultFormat holds the parsed message format, and the date format of a
+// DefaultFormatter.
+type defaultFormat struct {
+	// segments are the parts of the message format.
+	segments []formatSegment
+
+	// dateFormat is the layout used by {date} placeholders without a layout.
+	dateFormat string
+
+	// location is the time zone of the dates, or nil to keep the time zone
+	// of the message time.
+	location *time.Location
+
+	// size is the length of the literal parts of the message format.
+	size int
+
+	// caller defines whether the message format renders the caller.
+	caller bool
+
+	// stack defines whether the message format renders the stack.
+	stack bool
 }
 
 // NewDefaultFormatter creates and returns a new DefaultFormatter instance.
 func NewDefaultFormatter(messageFormat, dateFormat string) *DefaultFormatter {
-	messageFormat, dateFormat = SanitizeForDate(messageFormat, dateFormat)
-	placeholders := make(map[string]func(string) string)
-	return &DefaultFormatter{messageFormat, dateFormat, placeholders}
+	f := &DefaultFormatter{}
+	f.format.Store(newDefaultFormat(messageFormat, dateFormat))
+	return f
 }
 
 // SetFormat changes the set message format.
 func (f *DefaultFormatter) SetFormat(format string) {
-	f.messageFormat, f.dateFormat = SanitizeForDate(format, f.dateFormat)
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	current := f.load()
+	changed := newDefaultFormat(format, current.dateFormat)
+	changed.location = current.location
+	f.format.Store(changed)
+}
+
+// SetDateFormat changes the layout of the {date} placeholders which don't
+// have their own layout.
+func (f *DefaultFormatter) SetDateFormat(dateFormat string) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	changed := *f.load()
+	changed.dateFormat = dateFormat
+	f.format.Store(&changed)
+}
+
+// SetLocation changes the time zone of the formatted dates, e.g. time.UTC.
+// The local time zone is used when nil.
+func (f *DefaultFormatter) SetLocation(loc *time.Location) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	changed := *f.load()
+	changed.location = loc
+	f.format.Store(&changed)
 }
 
 // PlaceholderFunc adds a callback function which provides a replacement for key in a string format.
 func (f *DefaultFormatter) PlaceholderFunc(key string, fn func(string) string) {
-	f.funcs[key] = fn
+	f.funcs.add(key, fn)
+}
+
+// PlaceholderEntryFunc adds a callback function which provides a replacement
+// for key, and is given the entry being formatted. Values which are not
+// strings are formatted using fmt.Sprint. The entry must not be modified.
+func (f *DefaultFormatter) PlaceholderEntryFunc(key string, fn func(entry *Entry) interface{}) {
+	f.funcs.addEntry(key, fn)
+}
+
+// Format formats a log message for the given level. The fields are rendered
+// in place of the {fields} placeholder. The caller placeholders are rendered
+// empty, because the caller is only known to FormatEntry.
+func (f *DefaultFormatter) Format(name string, level Level, fields Fields, v ...interface{}) string {
+	return f.FormatEntry(argsEntry(name, level, fields, v))
+}
+
+// UsesCaller returns whether the format contains a placeholder rendering the
+// caller.
+func (f *DefaultFormatter) UsesCaller() bool {
+	return f.load().caller
+}
+
+// UsesStack returns whether the format contains the {stack} placeholder.
+func (f *DefaultFormatter) UsesStack() bool {
+	return f.load().stack
+}
+
+// FormatEntry formats the entry. Placeholders which are not known are
+// written as they are, without applying their modifiers.
+func (f *DefaultFormatter) FormatEntry(entry *Entry) string {
+	return f.formatEntry(entry, "")
+}
+
+// formatColor implements colorFormatter.formatColor.
+func (f *DefaultFormatter) formatColor(entry *Entry, color string) string {
+	return f.formatEntry(entry, color)
+}
+
+// formatEntry formats the entry. The {level} placeholders are colored using
+// the SGR parameters of the color, after their modifiers have been applied,
+// unless the color is empty.
+func (f *DefaultFormatter) formatEntry(entry *Entry, color string) string {
+	format := f.load()
+	t := entry.Time
+	if format.location != nil {
+		t = t.In(format.location)
+	}
+	buf := make([]byte, 0, format.size+len(entry.Message)+64)
+	for _, segment := range format.segments {
+		if !segment.placeholder {
+			buf = append(buf, segment.text...)
+			continue
+		}
+
+		start := len(buf)
+		switch c := entry.Caller; segment.key {
+		case "date":
+			layout := segment.arg
+			if layout == "" {
+				layout = format.dateFormat
+			}
+			buf = appendDate(buf, t, layout)
+		case "level":
+			buf = append(buf, Levels[entry.Level]...)
+		case "name":
+			buf = append(buf, entry.Name...)
+		case "message":
+			buf = append(buf, entry.Message...)
+		case "fields":
+			buf = append(buf, FormatFields(entry.Fields)...)
+		case "stack":
+			buf = append(buf, entry.Stack...)
+		case "file":
+			if c != nil {
+				buf = append(buf, c.ShortFile()...)
+			}
+		case "line":
+			if c != nil {
+				buf = strconv.AppendInt(buf, int64(c.Line), 10)
+			}
+		case "func":
+			if c != nil {
+				buf = append(buf, c.ShortFunc()...)
+			}
+		case "caller":
+			if c != nil {
+				buf = append(buf, c.String()...)
+			}
+		default:
+			fn, ok := f.funcs.load().funcs[segment.key]
+			if !ok {
+				buf = append(buf, '{')
+				buf = append(buf, segment.text...)
+				buf = append(buf, '}')
+				continue
+			}
+			// The callback is given a copy, so the entry only escapes to
+			// the heap when a callback is used.
+			copied := *entry
+			switch value := fn(&copied).(type) {
+			case string:
+				buf = append(buf, value...)
+			default:
+				buf = append(buf, fmt.Sprint(value)...)
+			}
+		}
+
+		if len(segment.modifiers) > 0 {
+			value := string(buf[start:])
+			for _, modify := range segment.modifiers {
+				value = modify(value)
+			}
+			buf = append(buf[:start], value...)
+		}
+		if color != "" && segment.key == "level" {
+			buf = appendColor(buf[:start], color, string(buf[start:]))
+		}
+	}
+
+	return string(buf)
+}
+
+// load returns the formats being used.
+func (f *DefaultFormatter) load() *defaultFormat {
+	return f.format.Load().(*defaultFormat)
+}
+
+// newDefaultFormat returns a *defaultFormat instance for the message format.
+func newDefaultFormat(messageFormat, dateFormat string) *defaultFormat {
+	format := &defaultFormat{
+		segments:   parseFormat(messageFormat),
+		dateFormat: dateFormat,
+	}
+	for _, segment := range format.segments {
+		if !segment.placeholder {
+			format.size += len(segment.text)
+			continue
+		}
+		switch segment.key {
+		case "file", "line", "func", "caller":
+			format.caller = true
+		case "stack":
+			format.stack = true
+		}
+	}
+
+	return format
 }
 
-// Format formats a log message for the given level.
-func (f *DefaultFormatter) Format(name string, level Level, v ...interface{}) string {
-	placeholders := map[string]string{
-		"{date}":    (time.Now()).Format(f.dateFormat),
-		"{level}":   Levels[level],
-		"{message}": fmt.Sprint(v...),
-		"{name}":    name,
+// formatSegment is a part of a parsed message format, which is either
+// literal text, or a placeholder.
+type formatSegment struct {
+	// text is the literal text, or the placeholder without its braces.
+	text string
+
+	// key is the key of the placeholder.
+	key string
+
+	// arg is the argument of the placeholder, such as the layout of a date.
+	arg string
+
+	// modifiers change the value of the placeholder, in order.
+	modifiers []modifier
+
+	// placeholder defines whether the segment is a placeholder.
+	placeholder bool
+}
+
+// parseFormat parses the message format into segments. A placeholder is a key
+// between braces, e.g. {message}, and "{{" and "}}" are literal braces. Other
+// braces which are not part of a placeholder are literal.
+func parseFormat(format string) []formatSegment {
+	var segments []formatSegment
+	literal := &strings.Builder{}
+	for i := 0; i < len(format); {
+		c := format[i]
+		if (c == '{' || c == '}') && i+1 < len(format) && format[i+1] == c {
+			literal.WriteByte(c)
+			i += 2
+			continue
+		}
+		end := -1
+		if c == '{' {
+			end = strings.IndexAny(format[i+1:], "{}")
+		}
+		if end < 0 || format[i+1+end] != '}' {
+			literal.WriteByte(c)
+			i++
+			continue
+		}
+
+		if literal.Len() > 0 {
+			segments = append(segments, formatSegment{text: literal.String()})
+			literal.Reset()
+		}
+		segments = append(segments, parsePlaceholder(format[i+1:i+1+end]))
+		i += end + 2
+	}
+	if literal.Len() > 0 {
+		segments = append(segments, formatSegment{text: literal.String()})
 	}
 
-	formatted := f.messageFormat
-	for placeholder, value := range placeholders {
-		formatted = strings.Replace(formatted, placeholder, value, -1)
+	return segments
+}
+
+// parsePlaceholder returns the segment for a placeholder, which is made of a
+// key, followed by modifiers separated by pipes, e.g. "level|lower|-9". A
+// date placeholder may have a layout before its modifiers, and a layout made
+// of digits, e.g. "date|2006", is not taken for a width. An empty layout, e.g.
+// "date||-25", is the date format of the formatter. The whole text is used as
+// the key when any of the modifiers is not valid.
+func parsePlaceholder(text string) formatSegment {
+	segment := formatSegment{text: text, key: text, placeholder: true}
+	parts := strings.Split(text, "|")
+	key, args := parts[0], parts[1:]
+	arg := ""
+	if key == "date" && len(args) > 0 {
+		_, err := strconv.Atoi(args[0])
+		if _, ok := parseModifier(args[0]); !ok || err == nil || args[0] == "" {
+			arg, args = args[0], args[1:]
+		}
+	}
+
+	modifiers := make([]modifier, 0, len(args))
+	for _, str := range args {
+		modify, ok := parseModifier(str)
+		if !ok {
+			return segment
+		}
+		modifiers = append(modifiers, modify)
+	}
+	segment.key, segment.arg = key, arg
+	if len(modifiers) > 0 {
+		segment.modifiers = modifiers
+	}
+
+	return segment
+}
+
+// appendDate appends the time formatted using the layout, which may be the
+// name of a preset: "rfc3339", "rfc3339nano", "unix" or "unixms".
+func appendDate(buf []byte, t time.Time, layout string) []byte {
+	switch layout {
+	case "unix":
+		return strconv.AppendInt(buf, t.Unix(), 10)
+	case "unixms":
+		return strconv.AppendInt(buf, t.UnixNano()/int64(time.Millisecond), 10)
 	}
-	for key, fn := range f.funcs {
-		formatted = strings.Replace(formatted, "{"+key+"}", fn(key), -1)
+	if preset, ok := dateLayouts[layout]; ok {
+		layout = preset
 	}
 
-	return formatted
+	return t.AppendFormat(buf, layout)
 }
 
 // SanitizeForDate replaces date placeholders containing a date format with
 // a plain {date} placeholder. The altered message format is returned, along
-// with the found date format.
+// with the date format of the first placeholder. It's used by formatters with
+// a single date, as the DefaultFormatter keeps the layout of each placeholder.
 func SanitizeForDate(messageFormat, dateFormat string) (string, string) {
-	regex := regexp.MustCompile(`{date\|([^}]+)}`)
-	captured := regex.FindStringSubmatch(messageFormat)
+	captured := dateRegexp.FindStringSubmatch(messageFormat)
 	if len(captured) == 2 {
 		dateFormat = captured[1]
-		messageFormat = regex.ReplaceAllString(messageFormat, "{date}")
+		messageFormat = dateRegexp.ReplaceAllString(messageFormat, "{date}")
 	}
 
 	return messageFormat, dateFormat
 }
+
+// FormatFields returns the fields as a string of space separated key=value
+// pairs, sorted by key. Values are quoted in the manner of the
+// LogfmtFormatter.
+func FormatFields(fields Fields) string {
+	if len(fields) == 0 {
+		return ""
+	}
+
+	buf := &bytes.Buffer{}
+	for _, key := range sortedKeys(fields) {
+		writeLogfmt(buf, key, fmt.Sprint(fields[key]))
+	}
+
+	return buf.String()
+}
+
+// placeholderFuncs stores the callbacks added with PlaceholderFunc. The
+// callbacks are replaced rather than modified, so they can be read without
+// taking a lock. The zero value is ready to use.
+type placeholderFuncs struct {
+	mu    sync.Mutex
+	value atomic.Value
+}
+
+// funcMap holds placeholder callbacks, and their keys in sorted order.
+type funcMap struct {
+	keys  []string
+	funcs map[string]func(*Entry) interface{}
+}
+
+// add stores the callback for the key.
+func (p *placeholderFuncs) add(key string, fn func(string) string) {
+	p.addEntry(key, func(*Entry) interface{} {
+		return fn(key)
+	})
+}
+
+// addEntry stores the callback, which is given the entry, for the key.
+func (p *placeholderFuncs) addEntry(key string, fn func(*Entry) interface{}) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	current := p.load()
+	funcs := make(map[string]func(*Entry) interface{}, len(current.funcs)+1)
+	for k, f := range current.funcs {
+		funcs[k] = f
+	}
+	funcs[key] = fn
+
+	keys := make([]string, 0, len(funcs))
+	for k := range funcs {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	p.value.Store(funcMap{keys, funcs})
+}
+
+// load returns the stored callbacks.
+func (p *placeholderFuncs) load() funcMap {
+	funcs, _ := p.value.Load().(funcMap)
+	return funcs
+}
+
+// sortedKeys returns the keys of the fields in sorted order.
+func sortedKeys(fields Fields) []string {
+	keys := make([]string, 0, len(fields))
+	for key := range fields {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+
+	return keys
+}
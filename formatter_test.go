@@ -1,11 +1,17 @@
 package xlog
 
-import "testing"
+import (
+	"encoding/json"
+	"fmt"
+	"strings"
+	"testing"
+	"time"
+)
 
 // TestFormat -
 func TestFormat(t *testing.T) {
 	formatter := NewDefaultFormatter("{level} {message}", DefaultDateFormat)
-	actual := formatter.Format("testing", DebugLevel, "This is a test.")
+	actual := formatter.Format("testing", DebugLevel, nil, "This is a test.")
 	expected := "DEBUG This is a test."
 	ActualEquals(t, actual, expected)
 }
@@ -16,7 +22,143 @@ func TestPlaceholderFunc(t *testing.T) {
 	formatter.PlaceholderFunc("hostname", func(key string) string {
 		return "test-service"
 	})
-	actual := formatter.Format("testing", DebugLevel, "This is a test.")
+	actual := formatter.Format("testing", DebugLevel, nil, "This is a test.")
 	expected := "DEBUG test-service This is a test."
 	ActualEquals(t, actual, expected)
 }
+
+// TestPlaceholderEntryFunc -
+func TestPlaceholderEntryFunc(t *testing.T) {
+	severity := func(entry *Entry) interface{} {
+		return syslogSeverity(entry.Level)
+	}
+	shard := func(entry *Entry) interface{} {
+		return entry.Name[strings.LastIndexByte(entry.Name, '.')+1:] + "/" + fmt.Sprint(len(entry.Args))
+	}
+
+	formatter := NewDefaultFormatter("{severity_code} {shard|upper} {message}", DefaultDateFormat)
+	formatter.PlaceholderEntryFunc("severity_code", severity)
+	formatter.PlaceholderEntryFunc("shard", shard)
+	logger := New("db.shard7")
+	logger.Formatter = formatter
+	writer := NewMemoryWriter()
+	logger.AppendWriter(writer, DebugLevel)
+	logger.Warningf("Slow query %d of %d.", 1, 3)
+	ActualEquals(t, writer.String(), "4 SHARD7/2 Slow query 1 of 3.\n")
+
+	jsonFormatter := NewJSONFormatter(DefaultDateFormat)
+	jsonFormatter.TimeKey = ""
+	jsonFormatter.PlaceholderEntryFunc("severity_code", severity)
+	actual := jsonFormatter.Format("testing", ErrorLevel, nil, "Test.")
+	ActualEquals(t, actual, `{"level":"ERROR","name":"testing","message":"Test.","severity_code":3}`)
+
+	logfmtFormatter := NewLogfmtFormatter(DefaultDateFormat)
+	logfmtFormatter.TimeKey = ""
+	logfmtFormatter.PlaceholderEntryFunc("shard", shard)
+	actual = logfmtFormatter.Format("db.shard2", InfoLevel, nil, "Test", ".")
+	ActualEquals(t, actual, `level=INFO name=db.shard2 msg=Test. shard=shard2/2`)
+}
+
+// TestFormatFields -
+func TestFormatFields(t *testing.T) {
+	formatter := NewDefaultFormatter("{level} {message} {fields}", DefaultDateFormat)
+	fields := Fields{"user": "sean", "request_id": 42, "path": "/a b"}
+	actual := formatter.Format("testing", DebugLevel, fields, "This is a test.")
+	expected := `DEBUG This is a test. path="/a b" request_id=42 user=sean`
+	ActualEquals(t, actual, expected)
+}
+
+// TestJSONFormat -
+func TestJSONFormat(t *testing.T) {
+	formatter := NewJSONFormatter(DefaultDateFormat)
+	formatter.TimeKey = ""
+	formatter.MessageKey = "msg"
+	formatter.PlaceholderFunc("hostname", func(key string) string {
+		return "test-service"
+	})
+	actual := formatter.Format("testing", DebugLevel, Fields{"id": 42}, "This is \"a\"\ntest.")
+	expected := `{"level":"DEBUG","name":"testing","msg":"This is \"a\"\ntest.","hostname":"test-service","id":42}`
+	ActualEquals(t, actual, expected)
+
+	formatter = NewJSONFormatter(DefaultDateFormat)
+	formatter.SetFormat("{date|2006}")
+	var decoded map[string]interface{}
+	if err := json.Unmarshal([]byte(formatter.Format("testing", DebugLevel, nil, "test")), &decoded); err != nil {
+		t.Fatal(err)
+	}
+	if len(decoded["time"].(string)) != 4 {
+		t.Errorf("Expected a 4 digit year but got '%s'.", decoded["time"])
+	}
+}
+
+// TestLogfmtFormat -
+func TestLogfmtFormat(t *testing.T) {
+	formatter := NewLogfmtFormatter(DefaultDateFormat)
+	formatter.TimeKey = ""
+	formatter.PlaceholderFunc("hostname", func(key string) string {
+		return "test-service"
+	})
+	actual := formatter.Format("testing", InfoLevel, Fields{"id": 42, "empty": ""}, "This is \"a\"\ntest.")
+	expected := `level=INFO name=testing msg="This is \"a\"\ntest." hostname=test-service empty="" id=42`
+	ActualEquals(t, actual, expected)
+}
+
+// BenchmarkDefaultFormatter -
+func BenchmarkDefaultFormatter(b *testing.B) {
+	formatter := NewDefaultFormatter(DefaultMessageFormat, DefaultDateFormat)
+	b.ReportAllocs()
+	for i := 0; i < b.N; i++ {
+		formatter.Format("testing", InfoLevel, nil, "This is a test.")
+	}
+}
+
+// BenchmarkDefaultFormatterFields -
+func BenchmarkDefaultFormatterFields(b *testing.B) {
+	formatter := NewDefaultFormatter("{date} {name}.{level} {message} {fields}", DefaultDateFormat)
+	fields := Fields{"request_id": 42, "user": "sean"}
+	b.ReportAllocs()
+	for i := 0; i < b.N; i++ {
+		formatter.Format("testing", InfoLevel, fields, "This is a test.")
+	}
+}
+
+// TestFormatInjection -
+func TestFormatInjection(t *testing.T) {
+	formatter := NewDefaultFormatter("{{{level}}} {name}: {message} {unknown} {{name}}", DefaultDateFormat)
+	formatter.PlaceholderFunc("host", func(key string) string {
+		return "{name}"
+	})
+	actual := formatter.Format("testing", InfoLevel, nil, "User {name} at {date}.")
+	ActualEquals(t, actual, "{INFO} testing: User {name} at {date}. {unknown} {name}")
+
+	formatter.SetFormat("{host} {date|2006} {message")
+	actual = formatter.Format("testing", InfoLevel, nil, "Test.")
+	ActualEquals(t, actual, "{name} "+time.Now().Format("2006")+" {message")
+}
+
+// TestFormatDates -
+func TestFormatDates(t *testing.T) {
+	formatter := NewDefaultFormatter("{date|2006-01-02} {date|15:04:05} {date|unix} {date|unixms} {date|rfc3339nano} {date}", DefaultDateFormat)
+	entry := &Entry{Time: time.Date(2014, 11, 15, 9, 40, 28, 693000000, time.FixedZone("EST", -5*3600))}
+	actual := formatter.FormatEntry(entry)
+	ActualEquals(t, actual, "2014-11-15 09:40:28 1416062428 1416062428693 2014-11-15T09:40:28.693-05:00 2014-11-15 09:40:28.693")
+
+	formatter.SetLocation(time.UTC)
+	formatter.SetFormat("{date} {date|15:04}")
+	actual = formatter.FormatEntry(entry)
+	ActualEquals(t, actual, "2014-11-15 14:40:28.693 14:40")
+}
+
+// TestFormatModifiers -
+func TestFormatModifiers(t *testing.T) {
+	formatter := NewDefaultFormatter("[{level|-9}] [{level|lower|short}] [{name|max=4|upper|6}] {message|json} {host|upper} {level|bogus}", DefaultDateFormat)
+	formatter.PlaceholderFunc("host", func(key string) string {
+		return "web-1"
+	})
+	actual := formatter.Format("testing", InfoLevel, nil, `Say "hi".`)
+	ActualEquals(t, actual, `[INFO     ] [inf] [  TEST] "Say \"hi\"." WEB-1 {level|bogus}`)
+
+	formatter.SetFormat("{level|short}|{date||-25}|{date|2006|5}")
+	entry := &Entry{Time: time.Date(2014, 11, 15, 9, 40, 28, 0, time.UTC), Level: WarningLevel}
+	ActualEquals(t, formatter.FormatEntry(entry), "WRN|2014-11-15 09:40:28.000  | 2014")
+}
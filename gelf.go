@@ -0,0 +1,346 @@
+package xlog
+
+import (
+	"bytes"
+	"compress/gzip"
+	"compress/zlib"
+	"crypto/rand"
+	"encoding/binary"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net"
+	"os"
+	"regexp"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+const (
+	// DefaultGELFChunkSize is the maximum size of the UDP datagrams written by
+	// a GELFWriter, which suits the MTU of most networks.
+	DefaultGELFChunkSize = 1420
+
+	// gelfMaxChunks is the maximum number of chunks in a GELF message.
+	gelfMaxChunks = 128
+
+	// gelfChunkHeaderSize is the size of the header of each chunk.
+	gelfChunkHeaderSize = 12
+)
+
+// GELFCompression is the compression of the UDP datagrams written by a
+// GELFWriter.
+type GELFCompression int
+
+const (
+	// GzipCompression compresses datagrams with gzip.
+	GzipCompression GELFCompression = iota
+
+	// ZlibCompression compresses datagrams with zlib.
+	ZlibCompression
+
+	// NoCompression writes uncompressed datagrams.
+	NoCompression
+)
+
+// gelfFieldRegexp matches the characters which are not allowed in the names
+// of additional fields.
+var gelfFieldRegexp = regexp.MustCompile(`[^\w.\-]`)
+
+// GELFWriter is an implementation of EntryWriter which writes each message
+// to Graylog as a GELF 1.1 payload, over UDP or TCP. The payload contains the
+// level as a syslog severity, the message, the name of the logger as the
+// "_logger" field, and an additional field for each placeholder added with
+// PlaceholderFunc, and for each of the fields attached to the logger.
+//
+// UDP payloads are compressed, and split into chunks when they are larger
+// than ChunkSize. TCP payloads are not compressed, and are terminated with a
+// null byte. The connection is made again when a write fails. The writer is
+// safe for concurrent use, but its fields must be set before it's used.
+type GELFWriter struct {
+	// Network is the network of the server: "udp" or "tcp".
+	Network string
+
+	// Addr is the address of the server.
+	Addr string
+
+	// Host is the name of the host sending the messages.
+	Host string
+
+	// Compression is the compression of UDP datagrams.
+	Compression GELFCompression
+
+	// ChunkSize is the maximum size of UDP datagrams.
+	ChunkSize int
+
+	// Timeout is the time to wait to connect, or to write a message. There is
+	// no timeout when zero.
+	Timeout time.Duration
+
+	// funcs provide the values for additional fields.
+	funcs placeholderFuncs
+
+	// mu guards the connection.
+	mu sync.Mutex
+
+	// conn is the connection to the server, or nil when not connected.
+	conn net.Conn
+
+	// closed defines whether the writer has been closed.
+	closed bool
+}
+
+// NewGELFWriter creates and returns a new *GELFWriter instance which writes to
+// the server using the network, "udp" or "tcp". The host defaults to the
+// hostname of the machine.
+func NewGELFWriter(network, addr string) *GELFWriter {
+	host, _ := os.Hostname()
+	return &GELFWriter{
+		Network:     network,
+		Addr:        addr,
+		Host:        host,
+		Compression: GzipCompression,
+		ChunkSize:   DefaultGELFChunkSize,
+		Timeout:     DefaultSyslogTimeout,
+	}
+}
+
+// PlaceholderFunc adds a callback function which provides the value for an
+// additional field in each message.
+func (w *GELFWriter) PlaceholderFunc(key string, fn func(string) string) {
+	w.funcs.add(key, fn)
+}
+
+// PlaceholderEntryFunc adds a callback function which provides the value for
+// an additional field in each message, and is given the entry being written.
+// Numbers are written as they are, and other values using fmt.Sprint. The
+// entry must not be modified.
+func (w *GELFWriter) PlaceholderEntryFunc(key string, fn func(entry *Entry) interface{}) {
+	w.funcs.addEntry(key, fn)
+}
+
+// Open connects to the server.
+func (w *GELFWriter) Open() error {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	if w.closed {
+		return errors.New("xlog: gelf writer is closed")
+	}
+	if w.conn != nil {
+		return nil
+	}
+
+	return w.connect()
+}
+
+// Name returns the address of the server.
+func (w *GELFWriter) Name() string {
+	return fmt.Sprintf("gelf+%s://%s", w.Network, w.Addr)
+}
+
+// Write implements io.Writer.Write by writing the message at InfoLevel.
+func (w *GELFWriter) Write(p []byte) (int, error) {
+	err := w.WriteEntry(&Entry{Time: time.Now(), Level: InfoLevel, Message: string(bytes.TrimRight(p, "\n"))})
+	if err != nil {
+		return 0, err
+	}
+
+	return len(p), nil
+}
+
+// WriteEntry implements EntryWriter.WriteEntry. A write which fails is
+// retried once on a new connection.
+func (w *GELFWriter) WriteEntry(entry *Entry) error {
+	payload := w.payload(entry)
+
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	if w.closed {
+		return errors.New("xlog: gelf writer is closed")
+	}
+	for attempt := 0; ; attempt++ {
+		err := w.write(payload)
+		if err == nil {
+			return nil
+		}
+		if w.conn != nil {
+			w.conn.Close()
+			w.conn = nil
+		}
+		if attempt > 0 {
+			return err
+		}
+	}
+}
+
+// Close closes the connection to the server.
+func (w *GELFWriter) Close() error {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	w.closed = true
+	if w.conn == nil {
+		return nil
+	}
+	err := w.conn.Close()
+	w.conn = nil
+
+	return err
+}
+
+// payload returns the GELF payload for the entry.
+func (w *GELFWriter) payload(entry *Entry) []byte {
+	t := entry.Time
+	if t.IsZero() {
+		t = time.Now()
+	}
+	short, full := entry.Message, ""
+	if i := strings.IndexByte(short, '\n'); i >= 0 {
+		short, full = short[:i], short
+	}
+
+	obj := newJSONObject()
+	obj.add("version", "1.1")
+	obj.add("host", w.Host)
+	obj.add("short_message", short)
+	if full != "" {
+		obj.add("full_message", full)
+	}
+	obj.add("timestamp", json.Number(strconv.FormatFloat(float64(t.UnixNano()/int64(time.Millisecond))/1000, 'f', 3, 64)))
+	obj.add("level", syslogSeverity(entry.Level))
+	obj.add("_logger", entry.Name)
+
+	funcs := w.funcs.load()
+	for _, key := range funcs.keys {
+		if name := gelfFieldName(key); !obj.seen[name] {
+			obj.add(name, gelfValue(funcs.funcs[key](entry)))
+		}
+	}
+	for _, key := range sortedKeys(entry.Fields) {
+		if name := gelfFieldName(key); !obj.seen[name] {
+			obj.add(name, gelfValue(entry.Fields[key]))
+		}
+	}
+
+	return []byte(obj.String())
+}
+
+// write writes the payload, connecting to the server when needed. It must be
+// called with w.mu held.
+func (w *GELFWriter) write(payload []byte) error {
+	if w.conn == nil {
+		if err := w.connect(); err != nil {
+			return err
+		}
+	}
+	if w.Timeout > 0 {
+		w.conn.SetWriteDeadline(time.Now().Add(w.Timeout))
+	}
+
+	if !strings.HasPrefix(w.Network, "udp") {
+		_, err := w.conn.Write(append(payload, 0))
+		return err
+	}
+
+	data, err := w.compress(payload)
+	if err != nil {
+		return err
+	}
+	size := w.ChunkSize
+	if size <= gelfChunkHeaderSize {
+		size = DefaultGELFChunkSize
+	}
+	if len(data) <= size {
+		_, err = w.conn.Write(data)
+		return err
+	}
+
+	size -= gelfChunkHeaderSize
+	count := (len(data) + size - 1) / size
+	if count > gelfMaxChunks {
+		return fmt.Errorf("xlog: gelf message of %d bytes needs more than %d chunks", len(data), gelfMaxChunks)
+	}
+	var id [8]byte
+	if _, err := rand.Read(id[:]); err != nil {
+		binary.BigEndian.PutUint64(id[:], uint64(time.Now().UnixNano()))
+	}
+	chunk := make([]byte, 0, size+gelfChunkHeaderSize)
+	for i := 0; i < count; i++ {
+		end := (i + 1) * size
+		if end > len(data) {
+			end = len(data)
+		}
+		chunk = append(chunk[:0], 0x1e, 0x0f)
+		chunk = append(chunk, id[:]...)
+		chunk = append(chunk, byte(i), byte(count))
+		chunk = append(chunk, data[i*size:end]...)
+		if _, err := w.conn.Write(chunk); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// compress returns the payload compressed for UDP.
+func (w *GELFWriter) compress(payload []byte) ([]byte, error) {
+	var buf bytes.Buffer
+	var zw interface {
+		Write([]byte) (int, error)
+		Close() error
+	}
+	switch w.Compression {
+	case NoCompression:
+		return payload, nil
+	case ZlibCompression:
+		zw = zlib.NewWriter(&buf)
+	default:
+		zw = gzip.NewWriter(&buf)
+	}
+	if _, err := zw.Write(payload); err != nil {
+		return nil, err
+	}
+	if err := zw.Close(); err != nil {
+		return nil, err
+	}
+
+	return buf.Bytes(), nil
+}
+
+// connect connects to the server. It must be called with w.mu held.
+func (w *GELFWriter) connect() error {
+	dialer := net.Dialer{Timeout: w.Timeout}
+	conn, err := dialer.Dial(w.Network, w.Addr)
+	if err != nil {
+		return err
+	}
+	w.conn = conn
+
+	return nil
+}
+
+// gelfFieldName returns the key as the name of an additional field, which
+// starts with an underscore. The name "_id" is reserved, so "id" is written
+// as "__id".
+func gelfFieldName(key string) string {
+	name := "_" + gelfFieldRegexp.ReplaceAllString(key, "_")
+	if name == "_id" {
+		name = "__id"
+	}
+
+	return name
+}
+
+// gelfValue returns the value of an additional field, which must be a string
+// or a number.
+func gelfValue(value interface{}) interface{} {
+	switch v := value.(type) {
+	case string, int, int8, int16, int32, int64, uint, uint8, uint16, uint32, uint64, float32, float64, json.Number:
+		return v
+	case error:
+		return v.Error()
+	}
+
+	return fmt.Sprint(value)
+}
@@ -0,0 +1,181 @@
+package xlog
+
+import (
+	"bufio"
+	"bytes"
+	"compress/gzip"
+	"compress/zlib"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"net"
+	"strings"
+	"testing"
+	"time"
+)
+
+// GELFRead reads a datagram from the connection, reassembling chunked
+// messages, and returns the decompressed payload.
+func GELFRead(t *testing.T, conn net.PacketConn, compression GELFCompression) []byte {
+	buf := make([]byte, 65536)
+	var chunks [][]byte
+	for {
+		conn.SetReadDeadline(time.Now().Add(5 * time.Second))
+		n, _, err := conn.ReadFrom(buf)
+		if err != nil {
+			t.Fatal(err)
+		}
+		data := append([]byte(nil), buf[:n]...)
+		if !bytes.HasPrefix(data, []byte{0x1e, 0x0f}) {
+			return GELFDecompress(t, data, compression)
+		}
+		if chunks == nil {
+			chunks = make([][]byte, data[11])
+		}
+		chunks[data[10]] = data[12:]
+		if int(data[10]) == len(chunks)-1 {
+			return GELFDecompress(t, bytes.Join(chunks, nil), compression)
+		}
+	}
+}
+
+// GELFDecompress returns the decompressed data.
+func GELFDecompress(t *testing.T, data []byte, compression GELFCompression) []byte {
+	var r interface{ Read([]byte) (int, error) }
+	var err error
+	switch compression {
+	case NoCompression:
+		return data
+	case ZlibCompression:
+		r, err = zlib.NewReader(bytes.NewReader(data))
+	default:
+		r, err = gzip.NewReader(bytes.NewReader(data))
+	}
+	if err != nil {
+		t.Fatal(err)
+	}
+	payload, err := ioutil.ReadAll(r)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	return payload
+}
+
+// TestGELFUDP -
+func TestGELFUDP(t *testing.T) {
+	conn, err := net.ListenPacket("udp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer conn.Close()
+
+	w := NewGELFWriter("udp", conn.LocalAddr().String())
+	w.Host = "host"
+	w.PlaceholderFunc("service", func(string) string { return "api" })
+	logger := New("gelf.app")
+	logger.AppendFile(w, DebugLevel)
+	defer logger.Close()
+
+	logger.With("request_id", 42, "id", "x", "err", errors.New("timed out")).Error("Request\nfailed.")
+	var payload map[string]interface{}
+	if err := json.Unmarshal(GELFRead(t, conn, GzipCompression), &payload); err != nil {
+		t.Fatal(err)
+	}
+	expected := map[string]string{
+		"version":       "1.1",
+		"host":          "host",
+		"short_message": "Request",
+		"full_message":  "Request\nfailed.",
+		"level":         "3",
+		"_logger":       "gelf.app",
+		"_service":      "api",
+		"_request_id":   "42",
+		"__id":          "x",
+		"_err":          "timed out",
+	}
+	for key, value := range expected {
+		ActualEquals(t, fmt.Sprint(payload[key]), value)
+	}
+	if _, ok := payload["timestamp"].(float64); !ok {
+		t.Errorf("Expected a numeric timestamp, got %v.", payload["timestamp"])
+	}
+}
+
+// TestGELFChunking -
+func TestGELFChunking(t *testing.T) {
+	conn, err := net.ListenPacket("udp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer conn.Close()
+
+	w := NewGELFWriter("udp", conn.LocalAddr().String())
+	w.Compression = ZlibCompression
+	w.ChunkSize = 100
+	defer w.Close()
+
+	message := strings.Repeat("0123456789abcdef", 100)
+	w.WriteEntry(&Entry{Level: WarningLevel, Name: "gelf.app", Message: message, Fields: Fields{"size": 1600}})
+	var payload map[string]interface{}
+	if err := json.Unmarshal(GELFRead(t, conn, ZlibCompression), &payload); err != nil {
+		t.Fatal(err)
+	}
+	ActualEquals(t, fmt.Sprint(payload["short_message"]), message)
+	ActualEquals(t, fmt.Sprint(payload["level"]), "4")
+
+	w.Compression = NoCompression
+	err = w.WriteEntry(&Entry{Level: WarningLevel, Message: strings.Repeat(message, 10)})
+	if err == nil {
+		t.Error("Expected an error for a message needing too many chunks.")
+	}
+}
+
+// TestGELFTCP -
+func TestGELFTCP(t *testing.T) {
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer ln.Close()
+	messages := make(chan string, 2)
+	go func() {
+		conn, err := ln.Accept()
+		if err != nil {
+			return
+		}
+		defer conn.Close()
+		r := bufio.NewReader(conn)
+		for {
+			line, err := r.ReadString(0)
+			if err != nil {
+				return
+			}
+			messages <- strings.TrimSuffix(line, "\x00")
+		}
+	}()
+
+	w := NewGELFWriter("tcp", ln.Addr().String())
+	w.Host = "host"
+	defer w.Close()
+	for _, msg := range []string{"First.\n", "Second.\n"} {
+		if _, err := w.Write([]byte(msg)); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	for _, expected := range []string{"First.", "Second."} {
+		select {
+		case msg := <-messages:
+			var payload map[string]interface{}
+			if err := json.Unmarshal([]byte(msg), &payload); err != nil {
+				t.Fatal(err)
+			}
+			ActualEquals(t, fmt.Sprint(payload["short_message"]), expected)
+			ActualEquals(t, fmt.Sprint(payload["level"]), "6")
+		case <-time.After(5 * time.Second):
+			t.Fatal("Timed out waiting for message.")
+		}
+	}
+}
@@ -1,109 +1,213 @@
 package xlog
 
-import "io"
+import (
+	"io"
+	"sort"
+	"strings"
+	"sync"
+	"sync/atomic"
+)
 
 var (
-	// globalInstance stores the global logger.
-	globalInstance *DefaultLogger
+	// globalMu guards changes to the global logger and the registry.
+	globalMu sync.Mutex
+
+	// globalInstance stores the global *DefaultLogger, which is replaced rather
+	// than modified so it can be read without taking a lock.
+	globalInstance atomic.Value
 
 	// globalAppended stores whether files have been appended to the global logger.
 	globalAppended bool = false
 
+	// globalLoggersMu guards globalLoggers.
+	globalLoggersMu sync.RWMutex
+
 	// globalLoggers stores the loggers created by the GetLogger() function.
 	globalLoggers map[string]*DefaultLogger
 )
 
 // Instance returns the global logger.
 func Instance() *DefaultLogger {
-	if globalInstance == nil {
-		globalInstance = New("xlog")
-		globalInstance.Append("stdout", DebugLevel)
+	if logger, _ := globalInstance.Load().(*DefaultLogger); logger != nil {
+		return logger
+	}
+
+	globalMu.Lock()
+	defer globalMu.Unlock()
+	logger, _ := globalInstance.Load().(*DefaultLogger)
+	if logger == nil {
+		logger = New("xlog")
+		logger.Settings.Container.Append(Aliases["stdout"], DebugLevel)
 		globalAppended = false
+		globalInstance.Store(logger)
 	}
 
-	return globalInstance
+	return logger
 }
 
 // GetLogger returns the *DefaultLogger with the given name. The logger will be
 // created if it's not already been created. Only a single *DefaultLogger instance
 // is created for a name.
+//
+// Names are dot-separated paths, such as "app.db.pool". The logger is the
+// child of the logger named by its path without the last element, e.g.
+// "app.db", which is created when needed, and loggers with a single element
+// are children of the global logger. A logger inherits the formatter, outputs
+// and level of its nearest ancestor until it's given its own.
 func GetLogger(name string) *DefaultLogger {
+	if logger, ok := lookupLogger(name); ok {
+		return logger
+	}
+
+	globalLoggersMu.Lock()
+	defer globalLoggersMu.Unlock()
+	return getLogger(name)
+}
+
+// lookupLogger returns the logger with the given name, and whether it has
+// been created by GetLogger.
+func lookupLogger(name string) (*DefaultLogger, bool) {
+	globalLoggersMu.RLock()
+	defer globalLoggersMu.RUnlock()
+	logger, ok := globalLoggers[name]
+	return logger, ok
+}
+
+// getLogger returns the logger with the given name, creating it and any
+// missing ancestors. It must be called with globalLoggersMu held.
+func getLogger(name string) *DefaultLogger {
 	if globalLoggers == nil {
 		globalLoggers = make(map[string]*DefaultLogger)
 	}
-	if _, ok := globalLoggers[name]; !ok {
-		globalLoggers[name] = New(name)
+	if logger, ok := globalLoggers[name]; ok {
+		return logger
 	}
 
-	return globalLoggers[name]
+	var logger *DefaultLogger
+	if i := strings.LastIndex(name, "."); i > 0 {
+		logger = getLogger(name[:i]).newChild(name)
+	} else {
+		logger = NewFromSettings(name, newChildSettings(nil))
+		logger.global = true
+	}
+	globalLoggers[name] = logger
+
+	return logger
+}
+
+// Loggers returns the loggers created by GetLogger, sorted by name.
+func Loggers() []*DefaultLogger {
+	globalLoggersMu.RLock()
+	defer globalLoggersMu.RUnlock()
+	loggers := make([]*DefaultLogger, 0, len(globalLoggers))
+	for _, logger := range globalLoggers {
+		loggers = append(loggers, logger)
+	}
+	sort.Slice(loggers, func(i, j int) bool {
+		return loggers[i].Name < loggers[j].Name
+	})
+
+	return loggers
 }
 
 // Close releases any resources held by the global logger. The logger should
 // not be used again after calling this method without re-configuring it, as
 // this method sets the global instance to nil.
 func Close() {
-	Instance().Close()
-	globalInstance = nil
+	globalMu.Lock()
+	logger, _ := globalInstance.Load().(*DefaultLogger)
+	globalInstance.Store((*DefaultLogger)(nil))
+	globalMu.Unlock()
+
+	if logger != nil {
+		logger.Close()
+	}
 }
 
-// SetName sets the name of the global logger.
+// SetName sets the name of the global logger. It's safe to call while
+// messages are being logged, as the global logger is replaced by a copy
+// with the name, which shares its settings.
 func SetName(name string) {
-	Instance().Name = name
+	current := Instance()
+	globalMu.Lock()
+	defer globalMu.Unlock()
+	if loaded, _ := globalInstance.Load().(*DefaultLogger); loaded != nil {
+		current = loaded
+	}
+	logger := *current
+	logger.Name = name
+	globalInstance.Store(&logger)
 }
 
-// SetFormatter sets the formatter used by the global logger.
+// SetFormatter sets the formatter used by the global logger. It's safe to
+// call while messages are being logged.
 func SetFormatter(formatter Formatter) {
-	Instance().Formatter = formatter
+	Instance().SetFormatter(formatter)
 }
 
-// SetContainer sets the logger container used by the global logger.
+// SetContainer sets the logger container used by the global logger, and
+// closes the previous container. It's safe to call while messages are being
+// logged.
 func SetContainer(lc Container) {
-	Instance().Container = lc
+	Instance().SetContainer(lc)
 }
 
 // Enabled returns whether the global logger is enabled.
 func Enabled() bool {
-	return Instance().Enabled
+	return Instance().loadEnabled()
 }
 
 // SetEnabled sets whether the global logger is enabled.
 func SetEnabled(enabled bool) {
-	Instance().Enabled = enabled
+	Instance().SetEnabled(enabled)
+}
+
+// SetLevel sets the minimum level logged by the global logger, and by the
+// loggers which inherit their level from it.
+func SetLevel(level Level) {
+	Instance().SetLevel(level)
+}
+
+// IsEnabled returns whether a message at the given level would be logged by
+// the global logger.
+func IsEnabled(level Level) bool {
+	return Instance().IsEnabled(level)
+}
+
+// SetCallerSkip sets the number of frames skipped by the global logger when
+// looking up the caller.
+func SetCallerSkip(skip int) {
+	Instance().SetCallerSkip(skip)
 }
 
 // Append adds a file to the global logger.
 func Append(file string, level Level) {
-	if !globalAppended {
-		Instance().ClearAppended()
-		globalAppended = true
-	}
+	clearGlobalAppended()
 	Instance().Append(file, level)
 }
 
 // MultiAppend adds one or more files to the global logger.
 func MultiAppend(files []string, level Level) {
-	if !globalAppended {
-		Instance().ClearAppended()
-		globalAppended = true
-	}
+	clearGlobalAppended()
 	Instance().MultiAppend(files, level)
 }
 
 // AppendWriter adds a writer to the global logger.
 func AppendWriter(writer io.Writer, level Level) {
-	if !globalAppended {
-		Instance().ClearAppended()
-		globalAppended = true
-	}
+	clearGlobalAppended()
 	Instance().AppendWriter(writer, level)
 }
 
+// AppendFile adds a writer to the global logger, which is closed when the
+// global logger is closed.
+func AppendFile(file io.WriteCloser, level Level) {
+	clearGlobalAppended()
+	Instance().AppendFile(file, level)
+}
+
 // MultiAppendWriters adds one or more io.Writer instances to the global logger.
 func MultiAppendWriters(writers []io.Writer, level Level) {
-	if !globalAppended {
-		Instance().ClearAppended()
-		globalAppended = true
-	}
+	clearGlobalAppended()
 	Instance().MultiAppendWriters(writers, level)
 }
 
@@ -220,3 +324,15 @@ func Emergency(v ...interface{}) {
 func Emergencyf(format string, v ...interface{}) {
 	Instance().Emergencyf(format, v...)
 }
+
+// clearGlobalAppended removes the default stdout logger from the global logger
+// the first time a file or writer is appended to it.
+func clearGlobalAppended() {
+	logger := Instance()
+	globalMu.Lock()
+	defer globalMu.Unlock()
+	if !globalAppended {
+		logger.ClearAppended()
+		globalAppended = true
+	}
+}
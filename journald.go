@@ -0,0 +1,192 @@
+package xlog
+
+import (
+	"bytes"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"net"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+)
+
+// DefaultJournalSocket is the path of the socket systemd-journald listens on.
+const DefaultJournalSocket = "/run/systemd/journal/socket"
+
+// JournalWriter is an implementation of EntryWriter which writes each message
+// to systemd-journald using its native protocol, so the fields attached to
+// the loggers are kept as journal fields. Each entry is written with the
+// PRIORITY matching its level, the SYSLOG_IDENTIFIER, the MESSAGE, and a
+// field for each of Fields and the fields of the entry. Field names are
+// converted to upper case, and characters which are not allowed are replaced
+// with underscores.
+//
+// Entries which are too large to be sent in a datagram are written to a
+// sealed memory file, which is passed to journald instead. The writer is safe
+// for concurrent use, but its fields must be set before it's used.
+type JournalWriter struct {
+	// SocketPath is the path of the journald socket.
+	SocketPath string
+
+	// Identifier is the SYSLOG_IDENTIFIER of the entries. The name of the
+	// logger is used when empty.
+	Identifier string
+
+	// Fields are added to every entry.
+	Fields map[string]string
+
+	// mu guards the socket.
+	mu sync.Mutex
+
+	// conn is the socket used to write to journald, or nil until the first write.
+	conn *net.UnixConn
+
+	// closed defines whether the writer has been closed.
+	closed bool
+}
+
+// NewJournalWriter creates and returns a new *JournalWriter instance which
+// writes to DefaultJournalSocket.
+func NewJournalWriter() *JournalWriter {
+	return &JournalWriter{SocketPath: DefaultJournalSocket}
+}
+
+// Name returns the path of the journald socket.
+func (w *JournalWriter) Name() string {
+	return "journal:" + w.SocketPath
+}
+
+// Write implements io.Writer.Write by writing the message at InfoLevel.
+func (w *JournalWriter) Write(p []byte) (int, error) {
+	err := w.WriteEntry(&Entry{Level: InfoLevel, Message: string(bytes.TrimRight(p, "\n"))})
+	if err != nil {
+		return 0, err
+	}
+
+	return len(p), nil
+}
+
+// WriteEntry implements EntryWriter.WriteEntry.
+func (w *JournalWriter) WriteEntry(entry *Entry) error {
+	identifier := w.Identifier
+	if identifier == "" {
+		identifier = entry.Name
+	}
+
+	var buf bytes.Buffer
+	writeJournalField(&buf, "PRIORITY", strconv.Itoa(syslogSeverity(entry.Level)))
+	if identifier != "" {
+		writeJournalField(&buf, "SYSLOG_IDENTIFIER", identifier)
+	}
+	writeJournalField(&buf, "MESSAGE", entry.Message)
+	for _, key := range sortedStringKeys(w.Fields) {
+		if name := journalFieldName(key); name != "" {
+			writeJournalField(&buf, name, w.Fields[key])
+		}
+	}
+	for _, key := range sortedKeys(entry.Fields) {
+		if name := journalFieldName(key); name != "" {
+			value := entry.Fields[key]
+			if err, ok := value.(error); ok {
+				value = err.Error()
+			}
+			writeJournalField(&buf, name, fmt.Sprint(value))
+		}
+	}
+
+	return w.send(buf.Bytes())
+}
+
+// Close closes the socket.
+func (w *JournalWriter) Close() error {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	w.closed = true
+	if w.conn == nil {
+		return nil
+	}
+	err := w.conn.Close()
+	w.conn = nil
+
+	return err
+}
+
+// send writes the datagram to journald, passing it in a memory file when it's
+// too large.
+func (w *JournalWriter) send(data []byte) error {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	if w.closed {
+		return errors.New("xlog: journal writer is closed")
+	}
+	if w.conn == nil {
+		// The socket is not connected, because file descriptors cannot be
+		// passed on connected datagram sockets.
+		conn, err := net.ListenUnixgram("unixgram", &net.UnixAddr{Net: "unixgram"})
+		if err != nil {
+			return err
+		}
+		w.conn = conn
+	}
+
+	addr := &net.UnixAddr{Name: w.SocketPath, Net: "unixgram"}
+	_, _, err := w.conn.WriteMsgUnix(data, nil, addr)
+	if isJournalSizeError(err) {
+		err = sendJournalFile(w.conn, addr, data)
+	}
+
+	return err
+}
+
+// writeJournalField writes a field in the journal export format. Values
+// containing a newline are written with their length.
+func writeJournalField(buf *bytes.Buffer, name, value string) {
+	buf.WriteString(name)
+	if !strings.ContainsRune(value, '\n') {
+		buf.WriteByte('=')
+		buf.WriteString(value)
+		buf.WriteByte('\n')
+		return
+	}
+
+	var size [8]byte
+	binary.LittleEndian.PutUint64(size[:], uint64(len(value)))
+	buf.WriteByte('\n')
+	buf.Write(size[:])
+	buf.WriteString(value)
+	buf.WriteByte('\n')
+}
+
+// journalFieldName returns the key as a journal field name, which is made of
+// at most 64 upper case letters, digits and underscores, and starts with a
+// letter. An empty name is returned when the key has no letters.
+func journalFieldName(key string) string {
+	name := strings.Map(func(r rune) rune {
+		switch {
+		case r >= 'a' && r <= 'z':
+			return r - 'a' + 'A'
+		case (r >= 'A' && r <= 'Z') || (r >= '0' && r <= '9'):
+			return r
+		}
+		return '_'
+	}, key)
+	name = strings.TrimLeft(name, "_0123456789")
+	if len(name) > 64 {
+		name = name[:64]
+	}
+
+	return name
+}
+
+// sortedStringKeys returns the keys of the map in sorted order.
+func sortedStringKeys(m map[string]string) []string {
+	keys := make([]string, 0, len(m))
+	for key := range m {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+
+	return keys
+}
@@ -0,0 +1,109 @@
+//go:build linux
+// +build linux
+
+package xlog
+
+import (
+	"errors"
+	"io/ioutil"
+	"net"
+	"os"
+	"runtime"
+	"syscall"
+	"unsafe"
+)
+
+const (
+	// mfdCloexec and mfdAllowSealing are flags of memfd_create.
+	mfdCloexec      = 0x1
+	mfdAllowSealing = 0x2
+
+	// fAddSeals is the fcntl command adding seals to a memory file.
+	fAddSeals = 0x409
+
+	// journalSeals prevent the memory file from being changed once sealed.
+	journalSeals = 0x1 | 0x2 | 0x4 | 0x8
+)
+
+// memfdCreate maps architectures to the number of the memfd_create system
+// call, which is not defined by the syscall package for every architecture.
+var memfdCreate = map[string]uintptr{
+	"386":      356,
+	"amd64":    319,
+	"arm":      385,
+	"arm64":    279,
+	"loong64":  279,
+	"mips":     4354,
+	"mipsle":   4354,
+	"mips64":   5314,
+	"mips64le": 5314,
+	"ppc64":    360,
+	"ppc64le":  360,
+	"riscv64":  279,
+	"s390x":    350,
+}
+
+// isJournalSizeError returns whether the error means the datagram is too large
+// to be written to the socket.
+func isJournalSizeError(err error) bool {
+	return errors.Is(err, syscall.EMSGSIZE) || errors.Is(err, syscall.ENOBUFS)
+}
+
+// sendJournalFile writes the data to a sealed memory file, and passes the file
+// to journald. An unlinked file in /dev/shm is used when memory files are not
+// supported.
+func sendJournalFile(conn *net.UnixConn, addr *net.UnixAddr, data []byte) error {
+	file, err := journalMemfd(data)
+	if err != nil {
+		if file, err = journalTempFile(data); err != nil {
+			return err
+		}
+	}
+	defer file.Close()
+
+	_, _, err = conn.WriteMsgUnix(nil, syscall.UnixRights(int(file.Fd())), addr)
+	return err
+}
+
+// journalMemfd returns a sealed memory file containing the data.
+func journalMemfd(data []byte) (*os.File, error) {
+	trap, ok := memfdCreate[runtime.GOARCH]
+	if !ok {
+		return nil, syscall.ENOSYS
+	}
+	name, err := syscall.BytePtrFromString("journal-xlog")
+	if err != nil {
+		return nil, err
+	}
+	fd, _, errno := syscall.Syscall(trap, uintptr(unsafe.Pointer(name)), mfdCloexec|mfdAllowSealing, 0)
+	if errno != 0 {
+		return nil, errno
+	}
+
+	file := os.NewFile(fd, "journal-xlog")
+	if _, err := file.Write(data); err != nil {
+		file.Close()
+		return nil, err
+	}
+	if _, _, errno := syscall.Syscall(syscall.SYS_FCNTL, fd, fAddSeals, journalSeals); errno != 0 {
+		file.Close()
+		return nil, errno
+	}
+
+	return file, nil
+}
+
+// journalTempFile returns an unlinked temporary file containing the data.
+func journalTempFile(data []byte) (*os.File, error) {
+	file, err := ioutil.TempFile("/dev/shm", "journal-xlog.*")
+	if err != nil {
+		return nil, err
+	}
+	os.Remove(file.Name())
+	if _, err := file.Write(data); err != nil {
+		file.Close()
+		return nil, err
+	}
+
+	return file, nil
+}
@@ -0,0 +1,58 @@
+//go:build linux
+// +build linux
+
+package xlog
+
+import (
+	"io/ioutil"
+	"os"
+	"strings"
+	"syscall"
+	"testing"
+	"time"
+)
+
+// TestJournalWriterLarge -
+func TestJournalWriterLarge(t *testing.T) {
+	conn, name := JournalListener(t)
+	defer conn.Close()
+
+	w := NewJournalWriter()
+	w.SocketPath = name
+	defer w.Close()
+	message := strings.Repeat("x", 4<<20)
+	errs := make(chan error, 1)
+	go func() {
+		errs <- w.WriteEntry(&Entry{Level: ErrorLevel, Name: "journal.large", Message: message})
+	}()
+
+	buf, oob := make([]byte, 4096), make([]byte, 1024)
+	conn.SetReadDeadline(time.Now().Add(5 * time.Second))
+	_, oobn, _, _, err := conn.ReadMsgUnix(buf, oob)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := <-errs; err != nil {
+		t.Fatal(err)
+	}
+	msgs, err := syscall.ParseSocketControlMessage(oob[:oobn])
+	if err != nil || len(msgs) != 1 {
+		t.Fatalf("Expected a file descriptor but got %v.", err)
+	}
+	fds, err := syscall.ParseUnixRights(&msgs[0])
+	if err != nil || len(fds) != 1 {
+		t.Fatalf("Expected a file descriptor but got %v.", err)
+	}
+	file := os.NewFile(uintptr(fds[0]), "journal")
+	defer file.Close()
+	file.Seek(0, 0)
+	data, err := ioutil.ReadAll(file)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	fields := ParseJournal(t, data)
+	if fields["MESSAGE"] != message || fields["PRIORITY"] != "3" {
+		t.Errorf("Expected the large entry to be passed in a file.")
+	}
+}
@@ -0,0 +1,21 @@
+//go:build !linux
+// +build !linux
+
+package xlog
+
+import (
+	"errors"
+	"net"
+)
+
+// isJournalSizeError returns false, because large entries are only passed to
+// journald on Linux.
+func isJournalSizeError(err error) bool {
+	return false
+}
+
+// sendJournalFile returns an error, because passing large entries to journald
+// is only supported on Linux.
+func sendJournalFile(conn *net.UnixConn, addr *net.UnixAddr, data []byte) error {
+	return errors.New("xlog: journal entry is too large")
+}
@@ -0,0 +1,81 @@
+package xlog
+
+import (
+	"bytes"
+	"encoding/binary"
+	"errors"
+	"net"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+// JournalListener listens on a unixgram socket in place of journald.
+func JournalListener(t *testing.T) (*net.UnixConn, string) {
+	name := filepath.Join(t.TempDir(), "journal.sock")
+	conn, err := net.ListenUnixgram("unixgram", &net.UnixAddr{Name: name, Net: "unixgram"})
+	if err != nil {
+		t.Skip(err)
+	}
+	conn.SetReadBuffer(1 << 20)
+
+	return conn, name
+}
+
+// ParseJournal parses a datagram in the journal export format.
+func ParseJournal(t *testing.T, data []byte) map[string]string {
+	fields := make(map[string]string)
+	for len(data) > 0 {
+		i := bytes.IndexAny(data, "=\n")
+		if i < 0 {
+			t.Fatalf("Invalid journal datagram '%s'.", data)
+		}
+		name := string(data[:i])
+		if data[i] == '=' {
+			end := bytes.IndexByte(data, '\n')
+			fields[name] = string(data[i+1 : end])
+			data = data[end+1:]
+			continue
+		}
+		size := binary.LittleEndian.Uint64(data[i+1 : i+9])
+		fields[name] = string(data[i+9 : i+9+int(size)])
+		data = data[i+10+int(size):]
+	}
+
+	return fields
+}
+
+// TestJournalWriter -
+func TestJournalWriter(t *testing.T) {
+	conn, name := JournalListener(t)
+	defer conn.Close()
+
+	w := NewJournalWriter()
+	w.SocketPath = name
+	w.Fields = map[string]string{"service-version": "1.2"}
+	logger := New("journal.app")
+	logger.AppendFile(w, DebugLevel)
+	defer logger.Close()
+
+	logger.With("request_id", 42, "_private", "x", "err", errors.New("timed out")).Warning("Request\nfailed.")
+	buf := make([]byte, 4096)
+	conn.SetReadDeadline(time.Now().Add(5 * time.Second))
+	n, err := conn.Read(buf)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	fields := ParseJournal(t, buf[:n])
+	expected := map[string]string{
+		"PRIORITY":          "4",
+		"SYSLOG_IDENTIFIER": "journal.app",
+		"MESSAGE":           "Request\nfailed.",
+		"SERVICE_VERSION":   "1.2",
+		"REQUEST_ID":        "42",
+		"PRIVATE":           "x",
+		"ERR":               "timed out",
+	}
+	for key, value := range expected {
+		ActualEquals(t, fields[key], value)
+	}
+}
@@ -0,0 +1,199 @@
+package xlog
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"sync/atomic"
+)
+
+const (
+	// DefaultJSONTimeKey is the key used for the message date by the JSONFormatter.
+	DefaultJSONTimeKey = "time"
+
+	// DefaultJSONLevelKey is the key used for the message level by the JSONFormatter.
+	DefaultJSONLevelKey = "level"
+
+	// DefaultJSONNameKey is the key used for the logger name by the JSONFormatter.
+	DefaultJSONNameKey = "name"
+
+	// DefaultJSONMessageKey is the key used for the message by the JSONFormatter.
+	DefaultJSONMessageKey = "message"
+
+	// DefaultJSONStackKey is the key used for the stack by the JSONFormatter.
+	DefaultJSONStackKey = "stack"
+)
+
+// JSONFormatter is an implementation of the Formatter interface which formats
+// each message as a single line JSON object.
+// The formatter is safe for concurrent use, but the keys must be set before
+// it's used to format messages.
+type JSONFormatter struct {
+	// TimeKey is the key used for the date. The date is omitted when empty.
+	TimeKey string
+
+	// LevelKey is the key used for the level. The level is omitted when empty.
+	LevelKey string
+
+	// NameKey is the key used for the logger name. The name is omitted when empty.
+	NameKey string
+
+	// MessageKey is the key used for the message. The message is omitted when empty.
+	MessageKey string
+
+	// StackKey is the key used for the stack of messages at
+	// Settings.StackLevel or above. The stack is omitted when empty.
+	StackKey string
+
+	// dateFormat stores the layout used to format the date.
+	dateFormat atomic.Value
+
+	// funcs provide the values for extra keys.
+	funcs placeholderFuncs
+}
+
+// NewJSONFormatter creates and returns a new JSONFormatter instance which
+// formats dates using the given layout.
+func NewJSONFormatter(dateFormat string) *JSONFormatter {
+	f := &JSONFormatter{
+		TimeKey:    DefaultJSONTimeKey,
+		LevelKey:   DefaultJSONLevelKey,
+		NameKey:    DefaultJSONNameKey,
+		MessageKey: DefaultJSONMessageKey,
+		StackKey:   DefaultJSONStackKey,
+	}
+	f.dateFormat.Store(dateFormat)
+	return f
+}
+
+// SetFormat changes the date layout. The layout is taken from a {date|layout}
+// placeholder in the format, e.g. "{date|2006-01-02T15:04:05Z07:00}", and may
+// be one of the presets of the DefaultFormatter, such as "unixms", which is
+// written as a number. The rest of the format is ignored because the
+// structure of the output is fixed.
+func (f *JSONFormatter) SetFormat(format string) {
+	_, dateFormat := SanitizeForDate(format, f.dateFormat.Load().(string))
+	f.dateFormat.Store(dateFormat)
+}
+
+// SetDateFormat changes the date layout.
+func (f *JSONFormatter) SetDateFormat(dateFormat string) {
+	f.dateFormat.Store(dateFormat)
+}
+
+// PlaceholderFunc adds a callback function which provides the value for an
+// extra key in each message.
+func (f *JSONFormatter) PlaceholderFunc(key string, fn func(string) string) {
+	f.funcs.add(key, fn)
+}
+
+// PlaceholderEntryFunc adds a callback function which provides the value for
+// an extra key in each message, and is given the entry being formatted. The
+// value is encoded as JSON, so it may be a number, or any other type. The
+// entry must not be modified.
+func (f *JSONFormatter) PlaceholderEntryFunc(key string, fn func(entry *Entry) interface{}) {
+	f.funcs.addEntry(key, fn)
+}
+
+// Format formats a log message for the given level.
+func (f *JSONFormatter) Format(name string, level Level, fields Fields, v ...interface{}) string {
+	return f.FormatEntry(argsEntry(name, level, fields, v))
+}
+
+// UsesCaller returns false, as the caller is not formatted.
+func (f *JSONFormatter) UsesCaller() bool {
+	return false
+}
+
+// UsesStack returns whether the stack is formatted.
+func (f *JSONFormatter) UsesStack() bool {
+	return f.StackKey != ""
+}
+
+// FormatEntry formats the entry.
+func (f *JSONFormatter) FormatEntry(entry *Entry) string {
+	obj := newJSONObject()
+	if f.TimeKey != "" {
+		layout := f.dateFormat.Load().(string)
+		date := string(appendDate(nil, entry.Time, layout))
+		if layout == "unix" || layout == "unixms" {
+			obj.add(f.TimeKey, json.Number(date))
+		} else {
+			obj.add(f.TimeKey, date)
+		}
+	}
+	if f.LevelKey != "" {
+		obj.add(f.LevelKey, Levels[entry.Level])
+	}
+	if f.NameKey != "" {
+		obj.add(f.NameKey, entry.Name)
+	}
+	if f.MessageKey != "" {
+		obj.add(f.MessageKey, entry.Message)
+	}
+	if f.StackKey != "" && entry.Stack != "" {
+		obj.add(f.StackKey, entry.Stack)
+	}
+
+	funcs := f.funcs.load()
+	for _, key := range funcs.keys {
+		obj.add(key, funcs.funcs[key](entry))
+	}
+	for _, key := range sortedKeys(entry.Fields) {
+		obj.add(key, entry.Fields[key])
+	}
+
+	return obj.String()
+}
+
+// jsonObject builds a JSON object while preserving the order of the keys.
+type jsonObject struct {
+	buf  *bytes.Buffer
+	seen map[string]bool
+}
+
+// newJSONObject returns a new, empty *jsonObject instance.
+func newJSONObject() *jsonObject {
+	buf := &bytes.Buffer{}
+	buf.WriteByte('{')
+	return &jsonObject{buf, make(map[string]bool)}
+}
+
+// add appends the key and value to the object. A key which has already been
+// added is prefixed with "fields." so existing keys are not overwritten.
+func (o *jsonObject) add(key string, value interface{}) {
+	for o.seen[key] {
+		key = "fields." + key
+	}
+	o.seen[key] = true
+
+	if o.buf.Len() > 1 {
+		o.buf.WriteByte(',')
+	}
+	writeJSON(o.buf, key)
+	o.buf.WriteByte(':')
+	writeJSON(o.buf, value)
+}
+
+// String returns the encoded object.
+func (o *jsonObject) String() string {
+	return o.buf.String() + "}"
+}
+
+// writeJSON writes the JSON encoding of the value to the buffer. Errors are
+// encoded using their message, and values which cannot be encoded are
+// written as strings using fmt.Sprint.
+func writeJSON(buf *bytes.Buffer, value interface{}) {
+	if err, ok := value.(error); ok {
+		value = err.Error()
+	}
+
+	var b bytes.Buffer
+	enc := json.NewEncoder(&b)
+	enc.SetEscapeHTML(false)
+	if err := enc.Encode(value); err != nil {
+		b.Reset()
+		enc.Encode(fmt.Sprint(value))
+	}
+	buf.Write(bytes.TrimRight(b.Bytes(), "\n"))
+}
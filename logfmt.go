@@ -0,0 +1,182 @@
+package xlog
+
+import (
+	"bytes"
+	"fmt"
+	"strconv"
+	"strings"
+	"sync/atomic"
+	"unicode"
+)
+
+const (
+	// DefaultLogfmtTimeKey is the key used for the message date by the LogfmtFormatter.
+	DefaultLogfmtTimeKey = "time"
+
+	// DefaultLogfmtLevelKey is the key used for the message level by the LogfmtFormatter.
+	DefaultLogfmtLevelKey = "level"
+
+	// DefaultLogfmtNameKey is the key used for the logger name by the LogfmtFormatter.
+	DefaultLogfmtNameKey = "name"
+
+	// DefaultLogfmtMessageKey is the key used for the message by the LogfmtFormatter.
+	DefaultLogfmtMessageKey = "msg"
+
+	// DefaultLogfmtStackKey is the key used for the stack by the LogfmtFormatter.
+	DefaultLogfmtStackKey = "stack"
+)
+
+// LogfmtFormatter is an implementation of the Formatter interface which
+// formats each message as a line of key=value pairs, e.g.
+// time=2014-11-15T09:40:28Z level=INFO name=testing msg="Test message."
+// The formatter is safe for concurrent use, but the keys must be set before
+// it's used to format messages.
+type LogfmtFormatter struct {
+	// TimeKey is the key used for the date. The date is omitted when empty.
+	TimeKey string
+
+	// LevelKey is the key used for the level. The level is omitted when empty.
+	LevelKey string
+
+	// NameKey is the key used for the logger name. The name is omitted when empty.
+	NameKey string
+
+	// MessageKey is the key used for the message. The message is omitted when empty.
+	MessageKey string
+
+	// StackKey is the key used for the stack of messages at
+	// Settings.StackLevel or above. The stack is omitted when empty.
+	StackKey string
+
+	// dateFormat stores the layout used to format the date.
+	dateFormat atomic.Value
+
+	// funcs provide the values for extra keys.
+	funcs placeholderFuncs
+}
+
+// NewLogfmtFormatter creates and returns a new LogfmtFormatter instance which
+// formats dates using the given layout.
+func NewLogfmtFormatter(dateFormat string) *LogfmtFormatter {
+	f := &LogfmtFormatter{
+		TimeKey:    DefaultLogfmtTimeKey,
+		LevelKey:   DefaultLogfmtLevelKey,
+		NameKey:    DefaultLogfmtNameKey,
+		MessageKey: DefaultLogfmtMessageKey,
+		StackKey:   DefaultLogfmtStackKey,
+	}
+	f.dateFormat.Store(dateFormat)
+	return f
+}
+
+// SetFormat changes the date layout. The layout is taken from a {date|layout}
+// placeholder in the format, and may be one of the presets of the
+// DefaultFormatter, such as "rfc3339nano". The rest of the format is ignored.
+func (f *LogfmtFormatter) SetFormat(format string) {
+	_, dateFormat := SanitizeForDate(format, f.dateFormat.Load().(string))
+	f.dateFormat.Store(dateFormat)
+}
+
+// SetDateFormat changes the date layout.
+func (f *LogfmtFormatter) SetDateFormat(dateFormat string) {
+	f.dateFormat.Store(dateFormat)
+}
+
+// PlaceholderFunc adds a callback function which provides the value for an
+// extra key in each message.
+func (f *LogfmtFormatter) PlaceholderFunc(key string, fn func(string) string) {
+	f.funcs.add(key, fn)
+}
+
+// PlaceholderEntryFunc adds a callback function which provides the value for
+// an extra key in each message, and is given the entry being formatted. The
+// value is formatted using fmt.Sprint. The entry must not be modified.
+func (f *LogfmtFormatter) PlaceholderEntryFunc(key string, fn func(entry *Entry) interface{}) {
+	f.funcs.addEntry(key, fn)
+}
+
+// Format formats a log message for the given level.
+func (f *LogfmtFormatter) Format(name string, level Level, fields Fields, v ...interface{}) string {
+	return f.FormatEntry(argsEntry(name, level, fields, v))
+}
+
+// UsesCaller returns false, as the caller is not formatted.
+func (f *LogfmtFormatter) UsesCaller() bool {
+	return false
+}
+
+// UsesStack returns whether the stack is formatted.
+func (f *LogfmtFormatter) UsesStack() bool {
+	return f.StackKey != ""
+}
+
+// FormatEntry formats the entry.
+func (f *LogfmtFormatter) FormatEntry(entry *Entry) string {
+	buf := &bytes.Buffer{}
+	if f.TimeKey != "" {
+		writeLogfmt(buf, f.TimeKey, string(appendDate(nil, entry.Time, f.dateFormat.Load().(string))))
+	}
+	if f.LevelKey != "" {
+		writeLogfmt(buf, f.LevelKey, Levels[entry.Level])
+	}
+	if f.NameKey != "" {
+		writeLogfmt(buf, f.NameKey, entry.Name)
+	}
+	if f.MessageKey != "" {
+		writeLogfmt(buf, f.MessageKey, entry.Message)
+	}
+	if f.StackKey != "" && entry.Stack != "" {
+		writeLogfmt(buf, f.StackKey, entry.Stack)
+	}
+
+	funcs := f.funcs.load()
+	for _, key := range funcs.keys {
+		writeLogfmt(buf, key, fmt.Sprint(funcs.funcs[key](entry)))
+	}
+	for _, key := range sortedKeys(entry.Fields) {
+		writeLogfmt(buf, key, fmt.Sprint(entry.Fields[key]))
+	}
+
+	return buf.String()
+}
+
+// writeLogfmt writes a key=value pair to the buffer, separated from any
+// previous pair by a space.
+func writeLogfmt(buf *bytes.Buffer, key, value string) {
+	if buf.Len() > 0 {
+		buf.WriteByte(' ')
+	}
+	buf.WriteString(logfmtKey(key))
+	buf.WriteByte('=')
+	buf.WriteString(logfmtValue(value))
+}
+
+// logfmtKey replaces characters which are not allowed in a logfmt key with
+// underscores.
+func logfmtKey(key string) string {
+	if key == "" {
+		return "_"
+	}
+
+	return strings.Map(func(r rune) rune {
+		if r <= ' ' || r == '=' || r == '"' || !unicode.IsPrint(r) {
+			return '_'
+		}
+		return r
+	}, key)
+}
+
+// logfmtValue returns the value, which is quoted when it's empty or contains
+// spaces, equals signs, quotes, backslashes or control characters.
+func logfmtValue(value string) string {
+	if value == "" {
+		return `""`
+	}
+	for _, r := range value {
+		if r <= ' ' || r == '=' || r == '"' || r == '\\' || !unicode.IsPrint(r) {
+			return strconv.Quote(value)
+		}
+	}
+
+	return value
+}
@@ -1,5 +1,10 @@
 package xlog
 
+import (
+	"fmt"
+	"strings"
+)
+
 // Level describes a logging level.
 type Level int
 
@@ -62,12 +67,37 @@ var Levels = map[Level]string{
 
 // ParseLevel returns a level corresponding to the given string.
 func ParseLevel(str string) Level {
+	level, err := parseLevel(str)
+	if err != nil {
+		panic("Invalid level.")
+	}
+	return level
+}
+
+// parseLevel returns the level corresponding to the given string, ignoring
+// case, or an error when the string does not name a level.
+func parseLevel(str string) (Level, error) {
 	for level, value := range Levels {
-		if value == str {
-			return level
+		if strings.EqualFold(value, str) {
+			return level, nil
 		}
 	}
-	panic("Invalid level.")
+	return 0, fmt.Errorf("xlog: invalid level %q", str)
+}
+
+// levelString returns the names of the levels in the level, separated by "|".
+func levelString(level Level) string {
+	if name, ok := Levels[level]; ok {
+		return name
+	}
+
+	names := make([]string, 0, len(levelOrder))
+	for _, lev := range levelOrder {
+		if level&lev > 0 {
+			names = append(names, Levels[lev])
+		}
+	}
+	return strings.Join(names, "|")
 }
 
 // IsGreaterLevel returns whether the level is_greater_than is greater than that.
@@ -90,12 +120,18 @@ func searchForLevel(level Level) int {
 	panic("Invalid level.")
 }
 
+// Fields are key/value pairs which are attached to log messages.
+type Fields map[string]interface{}
+
 // Loggable is an interface that provides methods for logging messages to
 // various levels.
 type Loggable interface {
 	New(name string) Loggable
+	With(keyvals ...interface{}) Loggable
+	WithFields(fields Fields) Loggable
 	Writable() bool
 	Closed() bool
+	IsEnabled(level Level) bool
 	Log(level Level, v ...interface{})
 	Logf(level Level, format string, v ...interface{})
 	Debug(v ...interface{})
@@ -1,17 +1,22 @@
 package xlog
 
 import (
+	"context"
 	"fmt"
 	"io"
 	"os"
+	"sync"
+	"sync/atomic"
+	"time"
 )
 
 const (
 	// DefaultDateFormat is the date format to use when none has been specified.
 	DefaultDateFormat string = "2006-01-02 15:04:05.000"
 
-	// DefaultMessageFormat is the message format to use when none has been specified.
-	DefaultMessageFormat string = "{date|2006-01-02 15:04:05.000} {name}.{level} {message}"
+	// DefaultMessageFormat is the message format to use when none has been
+	// specified. Its date uses the date format of the formatter.
+	DefaultMessageFormat string = "{date} {name}.{level} {message}"
 
 	// DefaultFileOpenFlags defines the file open options.
 	DefaultFileOpenFlags int = os.O_RDWR | os.O_CREATE | os.O_APPEND
@@ -19,6 +24,14 @@ const (
 	// DefaultFileOpenMode defines the mode files are opened in.
 	DefaultFileOpenMode os.FileMode = 0666
 
+	// DefaultFileMaxSize defines the size in bytes at which appended files are
+	// rotated. Files are not rotated when the size is zero.
+	DefaultFileMaxSize int64 = 0
+
+	// DefaultFileMaxBackups defines the maximum number of rotated files to keep.
+	// All rotated files are kept when the value is zero.
+	DefaultFileMaxBackups = 0
+
 	// DefaultPanicOnFileErrors defines whether the logger should panic when opening a file
 	// fails. When set to false, any file open errors are ignored, and the file won't be
 	// appended.
@@ -26,6 +39,12 @@ const (
 
 	// DefaultInitialCapacity defines the initial capacity for each type of logger.
 	DefaultInitialCapacity = 4
+
+	// DefaultStackLevel defines the minimum level at which the stack is captured.
+	DefaultStackLevel = CriticalLevel
+
+	// DefaultStackDepth defines the maximum number of frames in a captured stack.
+	DefaultStackDepth = 32
 )
 
 // Aliases maps file aliases to real file pointers.
@@ -37,31 +56,96 @@ var Aliases = map[string]io.Writer{
 
 // Settings represents a group of logger settings.
 type Settings struct {
-	// Enabled defines whether logging is enabled.
+	// Enabled defines whether logging is enabled. Use SetEnabled to change it
+	// while messages are being logged.
 	Enabled bool
 
-	// Formatter is used to format the log messages.
+	// level is the minimum level which is logged, or zero when the level is
+	// inherited. It's accessed atomically.
+	level int32
+
+	// callerSkip is the number of frames skipped when looking up the caller.
+	// It's accessed atomically.
+	callerSkip int32
+
+	// mu guards the Enabled, Formatter, Container, FatalOn, PanicOn and
+	// FileOpenMode fields, which are only read under the lock while messages
+	// are being logged, and never while a message is written.
+	mu sync.RWMutex
+
+	// ref is the reference to the container being written to, which is
+	// replaced when the Container field changes. It's guarded by mu.
+	ref *containerRef
+
+	// Formatter is used to format the log messages. The formatter of the
+	// parent logger is used when nil. Use SetFormatter to change the formatter
+	// while messages are being logged.
 	Formatter
 
-	// Container holds the appended file loggers.
+	// Container holds the appended file loggers. The container of the parent
+	// logger is used when nil. Use SetContainer to change the container while
+	// messages are being logged.
 	Container
 
-	// FatalOn represents levels that causes the application to exit.
+	// FatalOn represents levels that causes the application to exit. Use
+	// SetFatalOn to change it while messages are being logged.
 	FatalOn Level
 
-	// PanicOn represents levels that causes the application to panic.
+	// PanicOn represents levels that causes the application to panic. Use
+	// SetPanicOn to change it while messages are being logged.
 	PanicOn Level
 
+	// StackLevel is the minimum level at which the stack of the code logging
+	// a message is captured, for formatters rendering it with the {stack}
+	// placeholder or a stack key. Stacks are not captured when zero.
+	StackLevel Level
+
+	// StackDepth is the maximum number of frames in a captured stack.
+	StackDepth int
+
 	// FileFlags defines the file open options.
 	FileOpenFlags int
 
 	// FileMode defines the mode files are opened in.
 	FileOpenMode os.FileMode
 
+	// FileMaxSize defines the size in bytes at which appended files are rotated.
+	// Files are not rotated when the size is zero.
+	FileMaxSize int64
+
+	// FileMaxBackups defines the maximum number of rotated files to keep. All
+	// rotated files are kept when the value is zero.
+	FileMaxBackups int
+
+	// FileLocation is the time zone used to format {date|layout} placeholders
+	// in appended file names. The local time zone is used when nil.
+	FileLocation *time.Location
+
+	// FileCompress defines whether rotated files are compressed with gzip.
+	FileCompress bool
+
+	// FileMaxAge is the maximum age of rotated files. Rotated files are not
+	// removed because of their age when the value is zero.
+	FileMaxAge time.Duration
+
+	// FileMaxTotalSize is the maximum number of bytes used by the rotated files
+	// of each appended file. Rotated files are not removed because of their
+	// size when the value is zero.
+	FileMaxTotalSize int64
+
+	// FileErrorFunc is called with any error that happens while compressing or
+	// removing rotated files. Errors are ignored when nil.
+	FileErrorFunc func(error)
+
 	// PanicOnFileErrors defines whether the logger should panic when opening a file
 	// fails. When set to false, any file open errors are ignored, and the file won't be
 	// appended.
 	PanicOnFileErrors bool
+
+	// Color defines whether the levels of messages written to the appended
+	// aliases, such as "stdout", are colored when the alias is a terminal.
+	// Appended system files are never colored. See ColorWriter.
+	Color bool
 }
 
 // NewDefaultSettings returns a new *Settings instance.
@@ -72,17 +156,271 @@ func NewDefaultSettings(enabled bool) *Settings {
 		Container:         NewDefaultContainer(DefaultInitialCapacity),
 		FileOpenFlags:     DefaultFileOpenFlags,
 		FileOpenMode:      DefaultFileOpenMode,
+		FileMaxSize:       DefaultFileMaxSize,
+		FileMaxBackups:    DefaultFileMaxBackups,
 		PanicOnFileErrors: DefaultPanicOnFileErrors,
+		StackLevel:        DefaultStackLevel,
+		StackDepth:        DefaultStackDepth,
+	}
+}
+
+// newChildSettings returns a *Settings instance for a child logger, which
+// inherits the formatter, container and level of its parent. The remaining
+// settings are copied from the parent settings, or the defaults when nil.
+func newChildSettings(parent *Settings) *Settings {
+	if parent == nil {
+		parent = NewDefaultSettings(true)
+	}
+	fatalOn, panicOn := parent.loadExitLevels()
+	settings := &Settings{
+		Enabled:           true,
+		FatalOn:           fatalOn,
+		PanicOn:           panicOn,
+		StackLevel:        parent.StackLevel,
+		StackDepth:        parent.StackDepth,
+		FileOpenFlags:     parent.FileOpenFlags,
+		FileOpenMode:      parent.loadFileOpenMode(),
+		FileMaxSize:       parent.FileMaxSize,
+		FileMaxBackups:    parent.FileMaxBackups,
+		FileLocation:      parent.FileLocation,
+		FileCompress:      parent.FileCompress,
+		FileMaxAge:        parent.FileMaxAge,
+		FileMaxTotalSize:  parent.FileMaxTotalSize,
+		FileErrorFunc:     parent.FileErrorFunc,
+		PanicOnFileErrors: parent.PanicOnFileErrors,
+		Color:             parent.Color,
+	}
+	settings.SetCallerSkip(parent.CallerSkip())
+
+	return settings
+}
+
+// loadEnabled returns the Enabled field.
+func (s *Settings) loadEnabled() bool {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	return s.Enabled
+}
+
+// SetEnabled sets whether logging is enabled. It's safe to call while
+// messages are being logged.
+func (s *Settings) SetEnabled(enabled bool) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.Enabled = enabled
+}
+
+// loadExitLevels returns the FatalOn and PanicOn fields.
+func (s *Settings) loadExitLevels() (fatalOn, panicOn Level) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	return s.FatalOn, s.PanicOn
+}
+
+// SetFatalOn sets the levels which cause the application to exit. It's safe
+// to call while messages are being logged.
+func (s *Settings) SetFatalOn(levels Level) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.FatalOn = levels
+}
+
+// SetPanicOn sets the levels which cause the application to panic. It's safe
+// to call while messages are being logged.
+func (s *Settings) SetPanicOn(levels Level) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.PanicOn = levels
+}
+
+// loadFileOpenMode returns the FileOpenMode field.
+func (s *Settings) loadFileOpenMode() os.FileMode {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	return s.FileOpenMode
+}
+
+// CallerSkip returns the number of frames skipped when looking up the caller.
+func (s *Settings) CallerSkip() int {
+	return int(atomic.LoadInt32(&s.callerSkip))
+}
+
+// SetCallerSkip sets the number of frames skipped when looking up the code
+// which logged a message, in addition to the frames of this package. Functions
+// wrapping the logger set it to the number of wrapping functions, so the
+// caller is the code calling the wrappers. It's safe to call while messages
+// are being logged.
+func (s *Settings) SetCallerSkip(skip int) {
+	atomic.StoreInt32(&s.callerSkip, int32(skip))
+}
+
+// SetFormatter changes the formatter. It's safe to call while messages are
+// being logged. A nil formatter makes the logger inherit the formatter of its
+// parent.
+func (s *Settings) SetFormatter(formatter Formatter) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.Formatter = formatter
+}
+
+// SetContainer changes the container, and closes the previous container once
+// no message is being written to it. It's safe to call while messages are
+// being logged. A nil container makes the logger inherit the container of its
+// parent.
+func (s *Settings) SetContainer(container Container) {
+	old := s.swapContainer(container)
+	old.retire(old.Container != container)
+}
+
+// swapContainer changes the container, and returns the reference to the
+// previous container, which must be retired.
+func (s *Settings) swapContainer(container Container) *containerRef {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	old := s.syncContainer()
+	s.Container = container
+	s.ref = newContainerRef(container)
+
+	return old
+}
+
+// loadFormatter returns the Formatter field.
+func (s *Settings) loadFormatter() Formatter {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	return s.Formatter
+}
+
+// loadContainer returns the reference to the container being written to.
+func (s *Settings) loadContainer() *containerRef {
+	s.mu.RLock()
+	ref := s.ref
+	current := ref != nil && ref.Container == s.Container
+	s.mu.RUnlock()
+	if current {
+		return ref
 	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.syncContainer()
+}
+
+// syncContainer returns the reference to the container being written to,
+// which is replaced when the Container field has been assigned. It must be
+// called with s.mu held.
+func (s *Settings) syncContainer() *containerRef {
+	if s.ref != nil && s.ref.Container == s.Container {
+		return s.ref
+	}
+	if s.ref != nil {
+		// The Container field has been assigned, which does not close the
+		// previous container.
+		s.ref.retire(false)
+	}
+	s.ref = newContainerRef(s.Container)
+
+	return s.ref
+}
+
+// acquireContainer returns the reference to the container being written to,
+// which must be released once the message has been written. Nil is returned
+// when the container is inherited.
+func (s *Settings) acquireContainer() *containerRef {
+	for {
+		ref := s.loadContainer()
+		if ref.Container == nil {
+			return nil
+		}
+		if ref.acquire() {
+			return ref
+		}
+	}
+}
+
+// flusher is implemented by containers which write messages in the
+// background, such as the AsyncContainer, so the message causing the
+// application to exit or panic is written first.
+type flusher interface {
+	Flush(ctx context.Context) error
+}
+
+// containerRef counts the messages being written to a container, so a
+// container replaced by SetContainer is only closed once they have been
+// written.
+type containerRef struct {
+	Container
+
+	// refs is the number of messages being written, plus one until the
+	// reference is retired. It's accessed atomically.
+	refs int64
+
+	// close defines whether the container is closed once the reference is
+	// retired, and no message is being written. It's accessed atomically.
+	close int32
+}
+
+// newContainerRef returns a *containerRef instance for the container.
+func newContainerRef(container Container) *containerRef {
+	return &containerRef{Container: container, refs: 1}
+}
+
+// acquire adds a message being written to the container. False is returned
+// when the reference has been retired, and no message is being written.
+func (r *containerRef) acquire() bool {
+	for {
+		refs := atomic.LoadInt64(&r.refs)
+		if refs <= 0 {
+			return false
+		}
+		if atomic.CompareAndSwapInt64(&r.refs, refs, refs+1) {
+			return true
+		}
+	}
+}
+
+// release removes a message being written to the container, and closes the
+// container when the reference has been retired with close, and it was the
+// last message.
+func (r *containerRef) release() {
+	if atomic.AddInt64(&r.refs, -1) == 0 && atomic.LoadInt32(&r.close) == 1 && r.Container != nil {
+		r.Container.Close()
+	}
+}
+
+// retire marks the reference as replaced. The container is closed once no
+// message is being written to it when close is true.
+func (r *containerRef) retire(close bool) {
+	if close {
+		atomic.StoreInt32(&r.close, 1)
+	}
+	r.release()
 }
 
 // DefaultLogger is the default implementation of the Loggable interface.
+//
+// Loggers form a hierarchy. A logger created with the New method, or with a
+// dot-separated name by GetLogger, is the child of another logger, and it
+// inherits the formatter, container and level of its nearest ancestor which
+// has them, unless it's given its own. Changes made to an ancestor apply to
+// all the descendants inheriting from it, and disabling an ancestor disables
+// its descendants.
 type DefaultLogger struct {
 	// Name of the logger.
 	Name string
 
 	// Settings for the logger.
 	*Settings
+
+	// fields are attached to every message written by the logger.
+	fields Fields
+
+	// parent is the logger this logger inherits from.
+	parent *DefaultLogger
+
+	// global defines whether the logger inherits from the global logger when
+	// it has no parent.
+	global bool
 }
 
 // NewFromSettings returns a *DefaultLogger instance which uses the provided settings.
@@ -114,44 +452,197 @@ func NewWriters(name string, writers []io.Writer, level Level) *DefaultLogger {
 	return logger
 }
 
-// New returns a new logger using the settings of the parent logger.
+// New returns a new child logger, which inherits the formatter, container
+// and level of this logger, and is given its fields. The child is named by
+// the dot-separated path of this logger and the name, e.g. "app.db", except
+// for the children of the global logger, which are named by the name. The
+// children of the global logger and of the loggers returned by GetLogger are
+// returned by GetLogger, so there is a single logger for each name.
 func (l *DefaultLogger) New(name string) Loggable {
-	return NewFromSettings(name, l.Settings)
+	var logger *DefaultLogger
+	if l.Settings == Instance().Settings {
+		logger = GetLogger(name)
+	} else if registered, ok := lookupLogger(l.Name); ok && registered.Settings == l.Settings {
+		logger = GetLogger(l.Name + "." + name)
+	} else {
+		logger = l.newChild(l.Name + "." + name)
+		logger.fields = l.fields
+		return logger
+	}
+	if len(l.fields) == 0 {
+		return logger
+	}
+
+	return logger.WithFields(l.fields)
+}
+
+// newChild returns a new child logger with the given name.
+func (l *DefaultLogger) newChild(name string) *DefaultLogger {
+	logger := NewFromSettings(name, newChildSettings(l.Settings))
+	logger.parent = l
+	return logger
+}
+
+// With returns a child logger which attaches the given key/value pairs to
+// every message. The arguments are alternating keys and values, e.g.
+// With("request_id", id, "user_id", uid). Keys which are not strings are
+// converted using fmt.Sprint, and a key without a value is given a nil value.
+func (l *DefaultLogger) With(keyvals ...interface{}) Loggable {
+	fields := make(Fields, (len(keyvals)+1)/2)
+	for i := 0; i < len(keyvals); i += 2 {
+		key, ok := keyvals[i].(string)
+		if !ok {
+			key = fmt.Sprint(keyvals[i])
+		}
+		if i+1 < len(keyvals) {
+			fields[key] = keyvals[i+1]
+		} else {
+			fields[key] = nil
+		}
+	}
+
+	return l.WithFields(fields)
+}
+
+// WithFields returns a child logger which attaches the given fields to every
+// message. The child shares the settings of the parent, and the fields of the
+// parent are copied, so neither logger changes the fields of the other.
+func (l *DefaultLogger) WithFields(fields Fields) Loggable {
+	merged := make(Fields, len(l.fields)+len(fields))
+	for key, value := range l.fields {
+		merged[key] = value
+	}
+	for key, value := range fields {
+		merged[key] = value
+	}
+
+	return &DefaultLogger{
+		Name:     l.Name,
+		Settings: l.Settings,
+		fields:   merged,
+		parent:   l.parent,
+		global:   l.global,
+	}
+}
+
+// Fields returns a copy of the fields attached to the logger.
+func (l *DefaultLogger) Fields() Fields {
+	fields := make(Fields, len(l.fields))
+	for key, value := range l.fields {
+		fields[key] = value
+	}
+
+	return fields
+}
+
+// Parent returns the logger this logger inherits from, or nil.
+func (l *DefaultLogger) Parent() *DefaultLogger {
+	if l.parent != nil {
+		return l.parent
+	}
+	if l.global {
+		if root := Instance(); root != l {
+			return root
+		}
+	}
+
+	return nil
+}
+
+// SetLevel sets the minimum level which is logged. The logger and its
+// descendants which inherit the level ignore messages below the level. A
+// level of zero makes the logger inherit the level of its parent again.
+func (l *DefaultLogger) SetLevel(level Level) {
+	atomic.StoreInt32(&l.Settings.level, int32(level))
+}
+
+// Level returns the minimum level which is logged, which is inherited from
+// the nearest ancestor with a level when the logger has none. DebugLevel is
+// returned when no level has been set.
+func (l *DefaultLogger) Level() Level {
+	for n := l; n != nil; n = n.Parent() {
+		if level := Level(atomic.LoadInt32(&n.Settings.level)); level != 0 {
+			return level
+		}
+	}
+
+	return DebugLevel
+}
+
+// enabled returns whether logging is enabled for the logger and all of its
+// ancestors, so disabling a logger disables its descendants.
+func (l *DefaultLogger) enabled() bool {
+	for n := l; n != nil; n = n.Parent() {
+		if !n.Settings.loadEnabled() {
+			return false
+		}
+	}
+
+	return true
+}
+
+// IsEnabled returns whether a message at the given level would be logged,
+// which is when the logger is writable, and the level is not below Level().
+// It can be used to avoid building messages which would be discarded.
+func (l *DefaultLogger) IsEnabled(level Level) bool {
+	return level >= l.Level() && l.Writable()
 }
 
 // Writable returns true when logging is enabled, and the logger hasn't been closed.
 func (l *DefaultLogger) Writable() bool {
-	return l.Enabled && !l.Settings.Container.Closed()
+	c := l.container()
+	return l.enabled() && c != nil && !c.Closed()
 }
 
 // Closed returns whether the logger has been closed.
 func (l *DefaultLogger) Closed() bool {
-	return l.Settings.Container.Closed()
+	c := l.container()
+	return c != nil && c.Closed()
 }
 
 // Close disables logging and frees up resources used by the logger.
 // Note this method only closes files opened by the logger. It's the user's
 // responsibility to close files that were passed to the logger via the
 // AppendWriter method.
+//
+// A logger which inherits its container does not close it, as the container
+// belongs to an ancestor.
 func (l *DefaultLogger) Close() {
-	l.Settings.Container.Close()
-	l.Settings.Enabled = false
+	if c := l.Settings.loadContainer().Container; c != nil {
+		c.Close()
+	}
+	l.Settings.SetEnabled(false)
 }
 
 // Append adds a file that will be written to at the given level or greater.
 // The file argument may be either the full path to a system file, or one of the
-// aliases "stdout", "stdin", or "stderr".
+// aliases "stdout", "stdin", or "stderr". System files are rotated when
+// Settings.FileMaxSize is greater than zero, or when the file name contains
+// a {date|layout} placeholder, e.g. "/var/log/app-{date|2006-01-02}.log".
+// The levels written to aliases are colored when Settings.Color is true.
+// A logger which inherits the outputs of its parent is given its own
+// container, and stops inheriting them.
 func (l *DefaultLogger) Append(file string, level Level) {
 	if w, ok := Aliases[file]; ok {
-		l.Container.Append(w, level)
+		l.ownContainer().Append(l.aliasWriter(w), level)
 	} else {
 		w := l.open(file)
 		if w != nil {
-			l.Container.Append(w, level)
+			l.AppendFile(w, level)
 		}
 	}
 }
 
+// aliasWriter returns the writer of an alias, which is wrapped in a
+// ColorWriter when Settings.Color is true.
+func (l *DefaultLogger) aliasWriter(w io.Writer) io.Writer {
+	if l.Settings.Color {
+		return NewColorWriter(w)
+	}
+
+	return w
+}
+
 // MultiAppend adds one or more files to the logger.
 func (l *DefaultLogger) MultiAppend(files []string, level Level) {
 	for _, file := range files {
@@ -161,7 +652,21 @@ func (l *DefaultLogger) MultiAppend(files []string, level Level) {
 
 // AppendWriter adds a writer that will be written to at the given level or greater.
 func (l *DefaultLogger) AppendWriter(writer io.Writer, level Level) {
-	l.Container.Append(writer, level)
+	l.ownContainer().Append(writer, level)
+}
+
+// AppendFile adds a writer that will be written to at the given level or
+// greater. Unlike writers added with AppendWriter, the writer is closed when
+// the logger is closed, which suits writers such as the SyslogWriter. A
+// container which does not implement an AppendFile method, as the
+// DefaultContainer does, is given the writer with Append, and does not close it.
+func (l *DefaultLogger) AppendFile(file io.WriteCloser, level Level) {
+	container := l.ownContainer()
+	if fa, ok := container.(fileAppender); ok {
+		fa.AppendFile(file, level)
+	} else {
+		container.Append(file, level)
+	}
 }
 
 // MultiAppendWriters adds one or more io.Writer instances to the logger.
@@ -172,33 +677,103 @@ func (l *DefaultLogger) MultiAppendWriters(writers []io.Writer, level Level) {
 }
 
 // ClearAppended removes all the files that have been appended to the logger.
+// A logger which inherits the outputs of its parent stops inheriting them.
 func (l *DefaultLogger) ClearAppended() {
-	l.Container.Clear()
+	l.ownContainer().Clear()
 }
 
 // Log writes the message to each logger appended at the given level or higher.
-// Arguments are handled in the manner of fmt.Print.
+// Arguments are handled in the manner of fmt.Print. The message is not
+// formatted when the level is not enabled.
 func (l *DefaultLogger) Log(level Level, v ...interface{}) {
-	if l.Writable() {
-		message := l.Formatter.Format(l.Name, level, v...)
-		if message != "" {
-			for _, logger := range l.Container.Get(level) {
-				logger.Print(message)
+	if level < l.Level() || !l.enabled() {
+		return
+	}
+	l.log(level, fmt.Sprint(v...), v, v)
+}
+
+// log writes the message, which is made of the logged arguments, to each
+// logger appended at the given level or higher. Formatters which don't
+// implement EntryFormatter are given formatArgs, in the manner
+// of fmt.Print. No lock is held while the message is written, and a container
+// replaced while the message is written is closed once it has been written.
+// Containers which write in the background are flushed before the message
+// causes the application to exit or panic.
+func (l *DefaultLogger) log(level Level, message string, args, formatArgs []interface{}) {
+	ref := l.acquireContainer()
+	if ref == nil {
+		return
+	}
+	defer ref.release()
+	container := ref.Container
+	if container.Closed() {
+		return
+	}
+	formatter := l.formatter()
+	var entry *Entry
+	formatted := ""
+	if ef, ok := formatter.(EntryFormatter); ok {
+		entry = l.newEntry(formatter, level, message, args)
+		if ef.UsesCaller() {
+			entry.Caller = lookupCaller(l.CallerSkip())
+		}
+		if l.Settings.StackLevel != 0 && level >= l.Settings.StackLevel && ef.UsesStack() {
+			entry.Stack = captureStack(l.CallerSkip(), l.Settings.StackDepth)
+		}
+		formatted = ef.FormatEntry(entry)
+	} else if formatter != nil {
+		formatted = formatter.Format(l.Name, level, l.fields, formatArgs...)
+	}
+	if formatted != "" {
+		for _, logger := range container.Get(level) {
+			logger.Print(formatted)
+		}
+	}
+	if ec, ok := container.(entryContainer); ok {
+		if writers := ec.entryWriters(level); len(writers) > 0 {
+			if entry == nil {
+				entry = l.newEntry(formatter, level, message, args)
+			}
+			for _, writer := range writers {
+				writer.WriteEntry(entry)
 			}
 		}
+	}
 
-		if l.Settings.FatalOn&level > 0 {
-			os.Exit(1)
-		} else if l.Settings.PanicOn&level > 0 {
-			panic(message)
+	fatalOn, panicOn := l.Settings.loadExitLevels()
+	if (fatalOn|panicOn)&level > 0 {
+		if f, ok := container.(flusher); ok {
+			f.Flush(context.Background())
 		}
 	}
+	if fatalOn&level > 0 {
+		os.Exit(1)
+	} else if panicOn&level > 0 {
+		panic(formatted)
+	}
+}
+
+// newEntry returns an *Entry instance for the message, which is formatted
+// using the formatter.
+func (l *DefaultLogger) newEntry(formatter Formatter, level Level, message string, args []interface{}) *Entry {
+	return &Entry{
+		Time:      time.Now(),
+		Level:     level,
+		Name:      l.Name,
+		Message:   message,
+		Args:      args,
+		Fields:    l.fields,
+		formatter: formatter,
+	}
 }
 
 // Logf writes the message to each logger appended at the given level or higher.
 // Arguments are handled in the manner of fmt.Printf.
 func (l *DefaultLogger) Logf(level Level, format string, v ...interface{}) {
-	l.Log(level, fmt.Sprintf(format, v...))
+	if l.IsEnabled(level) {
+		message := fmt.Sprintf(format, v...)
+		l.log(level, message, v, []interface{}{message})
+	}
 }
 
 // Debug writes to the logger at DebugLevel.
@@ -302,9 +877,61 @@ func (l *DefaultLogger) Writer(level Level) *LoggerWriter {
 	return NewLoggerWriter(l, level)
 }
 
+// formatter returns the formatter of the logger, or of its nearest ancestor
+// which has one.
+func (l *DefaultLogger) formatter() Formatter {
+	for n := l; n != nil; n = n.Parent() {
+		if formatter := n.Settings.loadFormatter(); formatter != nil {
+			return formatter
+		}
+	}
+
+	return nil
+}
+
+// container returns the container of the logger, or of its nearest ancestor
+// which has one.
+func (l *DefaultLogger) container() Container {
+	for n := l; n != nil; n = n.Parent() {
+		if container := n.Settings.loadContainer().Container; container != nil {
+			return container
+		}
+	}
+
+	return nil
+}
+
+// acquireContainer returns the reference to the container of the logger, or
+// of its nearest ancestor which has one, which must be released once the
+// message has been written. Nil is returned when there is no container.
+func (l *DefaultLogger) acquireContainer() *containerRef {
+	for n := l; n != nil; n = n.Parent() {
+		if ref := n.Settings.acquireContainer(); ref != nil {
+			return ref
+		}
+	}
+
+	return nil
+}
+
+// ownContainer returns the container of the logger, creating an empty one when
+// the logger inherits its container.
+func (l *DefaultLogger) ownContainer() Container {
+	s := l.Settings
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if ref := s.syncContainer(); ref.Container == nil {
+		ref.retire(false)
+		s.Container = NewDefaultContainer(DefaultInitialCapacity)
+		s.ref = newContainerRef(s.Container)
+	}
+
+	return s.Container
+}
+
 // open returns a file that logs can be written to.
-func (l *DefaultLogger) open(name string) *os.File {
-	w, err := os.OpenFile(name, l.Settings.FileOpenFlags, l.Settings.FileOpenMode)
+func (l *DefaultLogger) open(name string) io.WriteCloser {
+	w, err := l.openFile(name, l.Settings.loadFileOpenMode())
 	if err != nil {
 		if l.Settings.PanicOnFileErrors {
 			panic(err)
@@ -315,3 +942,28 @@ func (l *DefaultLogger) open(name string) *os.File {
 
 	return w
 }
+
+// openFile opens the named file in the given mode, returning a *RotatingFile
+// when the file is rotated.
+func (l *DefaultLogger) openFile(name string, mode os.FileMode) (io.WriteCloser, error) {
+	if l.Settings.FileMaxSize > 0 || IsDatePattern(name) {
+		r := NewRotatingFile(name, l.Settings.FileMaxSize, l.Settings.FileMaxBackups)
+		r.FileOpenFlags = l.Settings.FileOpenFlags
+		r.FileOpenMode = mode
+		r.Location = l.Settings.FileLocation
+		r.Compress = l.Settings.FileCompress
+		r.MaxAge = l.Settings.FileMaxAge
+		r.MaxTotalSize = l.Settings.FileMaxTotalSize
+		r.ErrorFunc = l.Settings.FileErrorFunc
+		if err := r.Open(); err != nil {
+			return nil, err
+		}
+		return r, nil
+	}
+
+	f, err := os.OpenFile(name, l.Settings.FileOpenFlags, mode)
+	if err != nil {
+		return nil, err
+	}
+	return f, nil
+}
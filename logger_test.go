@@ -2,9 +2,16 @@ package xlog
 
 import (
 	"fmt"
+	"io"
+	"io/ioutil"
+	"log"
+	"path/filepath"
 	"reflect"
 	"strings"
+	"sync"
+	"sync/atomic"
 	"testing"
+	"time"
 )
 
 // LoggerName is the default name for the test logger.
@@ -19,6 +26,15 @@ func LoggerFixture(level Level) (*DefaultLogger, *MemoryWriter) {
 	return logger, writer
 }
 
+// UniqueName returns a logger name which has not been used by the tests, so
+// the tests don't share the loggers returned by GetLogger.
+func UniqueName(prefix string) string {
+	return fmt.Sprintf("%s%d", prefix, atomic.AddInt64(&uniqueNames, 1))
+}
+
+// uniqueNames counts the names returned by UniqueName.
+var uniqueNames int64
+
 // ActualEquals asserts that actual equals expected.
 func ActualEquals(t *testing.T, actual, expected string) {
 	if actual != expected {
@@ -216,6 +232,79 @@ func TestEnabled(t *testing.T) {
 	ActualContains(t, writer.String(), expected)
 }
 
+// TestSetEnabled -
+func TestSetEnabled(t *testing.T) {
+	logger, writer := LoggerFixture(DebugLevel)
+	expected := "testing.DEBUG This is a test."
+
+	logger.SetEnabled(false)
+	logger.Debug("This is a test.")
+	ActualIsEmpty(t, writer.String())
+	if logger.Enabled {
+		t.Error("Expected SetEnabled to change the Enabled field.")
+	}
+
+	writer.Clear()
+	logger.Enabled = true
+	logger.Debug(expected)
+	ActualContains(t, writer.String(), expected)
+}
+
+// TestSetFormatterContainer -
+func TestSetFormatterContainer(t *testing.T) {
+	logger := New(LoggerName)
+	formatter := NewDefaultFormatter("{level}", DefaultDateFormat)
+	logger.SetFormatter(formatter)
+	if logger.Settings.Formatter != formatter {
+		t.Error("Expected SetFormatter to change the Formatter field.")
+	}
+
+	writer := NewMemoryWriter()
+	container := NewDefaultContainer(DefaultInitialCapacity)
+	logger.SetContainer(container)
+	if logger.Settings.Container != container {
+		t.Error("Expected SetContainer to change the Container field.")
+	}
+	logger.Settings.Container.Append(writer, DebugLevel)
+	logger.Settings.Formatter.SetFormat("{level} {message}")
+	logger.Info("This is a test.")
+	ActualEquals(t, writer.String(), "INFO This is a test.\n")
+}
+
+// CountingStringer counts the number of times it's formatted.
+type CountingStringer int
+
+func (c *CountingStringer) String() string {
+	*c++
+	return "counted"
+}
+
+// TestIsEnabled -
+func TestIsEnabled(t *testing.T) {
+	logger, writer := LoggerFixture(DebugLevel)
+	logger.SetLevel(WarningLevel)
+	if logger.IsEnabled(InfoLevel) || !logger.IsEnabled(WarningLevel) {
+		t.Error("Expected only WarningLevel and above to be enabled.")
+	}
+
+	var counter CountingStringer
+	logger.Info(&counter)
+	logger.Infof("%s", &counter)
+	ActualIsEmpty(t, writer.String())
+	if counter != 0 {
+		t.Errorf("Expected disabled messages not to be formatted, but were formatted %d times.", counter)
+	}
+
+	logger.SetLevel(DebugLevel)
+	logger.Infof("%s", &counter)
+	ActualContains(t, writer.String(), "testing.INFO counted")
+
+	logger.SetEnabled(false)
+	if logger.IsEnabled(EmergencyLevel) {
+		t.Error("Expected no levels to be enabled when the logger is disabled.")
+	}
+}
+
 // TestAliases -
 func TestAliases(t *testing.T) {
 	writer := NewMemoryWriter()
@@ -259,6 +348,29 @@ func TestInstance(t *testing.T) {
 	ActualContains(t, writer.String(), expected)
 }
 
+// TestSetName -
+func TestSetName(t *testing.T) {
+	name := Instance().Name
+	defer SetName(name)
+
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		for i := 0; i < 100; i++ {
+			SetName(fmt.Sprint("xlog", i%2))
+		}
+	}()
+	for i := 0; i < 100; i++ {
+		if Instance().Name == "" {
+			t.Fatal("Expected the global logger to have a name.")
+		}
+	}
+	<-done
+
+	SetName(LoggerName)
+	ActualEquals(t, Instance().Name, LoggerName)
+}
+
 // TestGetLogger -
 func TestGetLogger(t *testing.T) {
 	loggerA := GetLogger("a")
@@ -277,10 +389,267 @@ func TestGetLogger(t *testing.T) {
 
 // TestNewFromLogger -
 func TestNewFromLogger(t *testing.T) {
-	loggerA := GetLogger("a")
+	loggerA, writer := LoggerFixture(DebugLevel)
 	loggerB := loggerA.New("b").(*DefaultLogger)
-	if loggerA.Settings != loggerB.Settings {
-		t.Error("Expected both loggers to have the same settings.")
+	if loggerB.Parent() != loggerA {
+		t.Error("Expected loggerA to be the parent of loggerB.")
+	}
+
+	loggerB.Info("This is a test.")
+	ActualContains(t, writer.String(), "b.INFO This is a test.")
+
+	writer.Clear()
+	loggerA.SetLevel(WarningLevel)
+	loggerB.Info("This is a test.")
+	ActualIsEmpty(t, writer.String())
+
+	loggerB.SetLevel(InfoLevel)
+	loggerB.Info("This is a test.")
+	ActualContains(t, writer.String(), "b.INFO This is a test.")
+
+	writer.Clear()
+	loggerA.Info("This is a test.")
+	ActualIsEmpty(t, writer.String())
+}
+
+// TestHierarchy -
+func TestHierarchy(t *testing.T) {
+	name := UniqueName("test")
+	root, db, pool := GetLogger(name), GetLogger(name+".db"), GetLogger(name+".db.pool")
+	if pool.Parent() != db || db.Parent() != root {
+		t.Fatal("Expected loggers to be children of the logger named by their path.")
+	}
+	if root.Parent() != Instance() {
+		t.Fatal("Expected top level loggers to be children of the global logger.")
+	}
+
+	writer := NewMemoryWriter()
+	root.AppendWriter(writer, DebugLevel)
+	root.Formatter = NewDefaultFormatter("{name}.{level} {message}", DefaultDateFormat)
+
+	pool.Debug("This is a test.")
+	ActualEquals(t, writer.String(), name+".db.pool.DEBUG This is a test.\n")
+
+	writer.Clear()
+	db.SetLevel(ErrorLevel)
+	pool.Warning("This is a test.")
+	ActualIsEmpty(t, writer.String())
+	if pool.Level() != ErrorLevel {
+		t.Errorf("Expected the level %s but got %s.", Levels[ErrorLevel], Levels[pool.Level()])
+	}
+
+	child := NewMemoryWriter()
+	pool.AppendWriter(child, DebugLevel)
+	pool.Error("This is a test.")
+	ActualIsEmpty(t, writer.String())
+	ActualEquals(t, child.String(), name+".db.pool.ERROR This is a test.\n")
+}
+
+// TestHierarchyEnabled -
+func TestHierarchyEnabled(t *testing.T) {
+	logger, writer := LoggerFixture(DebugLevel)
+	child := logger.New("child")
+
+	logger.SetEnabled(false)
+	child.Info("This is a test.")
+	ActualIsEmpty(t, writer.String())
+	if child.Writable() {
+		t.Error("Expected the child of a disabled logger not to be writable.")
+	}
+
+	logger.Enabled = true
+	child.Info("This is a test.")
+	ActualContains(t, writer.String(), "testing.child.INFO This is a test.")
+}
+
+// TestHierarchyNew -
+func TestHierarchyNew(t *testing.T) {
+	name := UniqueName("test")
+	root := GetLogger(name)
+	db := root.With("key", "value").New("db").(*DefaultLogger)
+	if db.Name != name+".db" || db.Parent() != root {
+		t.Errorf("Expected the child of %s but got %s.", name, db.Name)
+	}
+	if _, ok := lookupLogger(name + ".db"); !ok {
+		t.Error("Expected the child to be registered.")
+	}
+	if db.Fields()["key"] != "value" {
+		t.Error("Expected the child to be given the fields.")
+	}
+
+	top := Instance().New(UniqueName("test")).(*DefaultLogger)
+	if top != GetLogger(top.Name) {
+		t.Error("Expected the child of the global logger to be a top level logger.")
+	}
+}
+
+// TestWith -
+func TestWith(t *testing.T) {
+	logger, writer := LoggerFixture(DebugLevel)
+	logger.Formatter = NewDefaultFormatter("{name}.{level} {message} {fields}", DefaultDateFormat)
+
+	child := logger.With("request_id", 42)
+	grandchild := child.WithFields(Fields{"user": "sean"})
+
+	grandchild.Debug("This is a test.")
+	ActualEquals(t, writer.String(), "testing.DEBUG This is a test. request_id=42 user=sean\n")
+
+	writer.Clear()
+	child.Debug("This is a test.")
+	ActualEquals(t, writer.String(), "testing.DEBUG This is a test. request_id=42\n")
+
+	writer.Clear()
+	logger.Debug("This is a test.")
+	ActualEquals(t, writer.String(), "testing.DEBUG This is a test. \n")
+
+	if child.(*DefaultLogger).Settings != logger.Settings {
+		t.Error("Expected the child logger to share the parent settings.")
+	}
+}
+
+// TestConcurrentUse - run with -race to check for data races.
+func TestConcurrentUse(t *testing.T) {
+	logger := New(LoggerName)
+	formatter := NewDefaultFormatter("{date} {level} {hostname} {message}", DefaultDateFormat)
+	logger.Formatter = formatter
+	json := NewJSONFormatter(DefaultDateFormat)
+	child := NewWriters("child", []io.Writer{ioutil.Discard}, DebugLevel)
+	child.Formatter = json
+
+	var wg sync.WaitGroup
+	stop := make(chan struct{})
+	for i := 0; i < 8; i++ {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			for {
+				select {
+				case <-stop:
+					return
+				default:
+				}
+				logger.Infof("Message %d.", i)
+				child.Debug("This is a test.")
+				GetLogger(fmt.Sprint("concurrent.logger", i%4)).Level()
+				Instance().Writable()
+			}
+		}(i)
+	}
+
+	for i := 0; i < 100; i++ {
+		logger.AppendWriter(ioutil.Discard, InfoLevel)
+		formatter.PlaceholderFunc("hostname", func(key string) string {
+			return "test-service"
+		})
+		formatter.SetFormat("{date|15:04:05} {level} {hostname} {message}")
+		json.PlaceholderFunc(fmt.Sprint("key", i%4), func(key string) string {
+			return key
+		})
+		json.SetFormat("{date|2006}")
+		logger.SetEnabled(i%2 == 0)
+		SetEnabled(i%2 == 0)
+		if i%10 == 0 {
+			logger.ClearAppended()
+		}
+	}
+	close(stop)
+	wg.Wait()
+	SetEnabled(true)
+}
+
+// TestAppendFileContainer -
+func TestAppendFileContainer(t *testing.T) {
+	name := filepath.Join(t.TempDir(), "app.log")
+	logger := New(LoggerName)
+	logger.Container = &BasicContainer{NewDefaultContainer(DefaultInitialCapacity)}
+	logger.Formatter = NewDefaultFormatter("{message}", DefaultDateFormat)
+	logger.Append(name, DebugLevel)
+	logger.Info("This is a test.")
+	logger.Close()
+
+	data, err := ioutil.ReadFile(name)
+	if err != nil {
+		t.Fatal(err)
+	}
+	ActualEquals(t, string(data), "This is a test.\n")
+}
+
+// TestNestedLogging -
+func TestNestedLogging(t *testing.T) {
+	inner, writer := LoggerFixture(DebugLevel)
+	outer := New("outer")
+	outer.AppendWriter(inner.Writer(InfoLevel), DebugLevel)
+
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		for i := 0; i < 1000; i++ {
+			outer.Debug("This is a test.")
+		}
+	}()
+	for i := 0; i < 1000; i++ {
+		inner.SetFormatter(NewDefaultFormatter("{message}", DefaultDateFormat))
+		outer.AppendWriter(ioutil.Discard, EmergencyLevel)
+	}
+	select {
+	case <-done:
+	case <-time.After(10 * time.Second):
+		t.Fatal("Timed out waiting for the nested loggers.")
+	}
+	ActualContains(t, writer.String(), "outer.DEBUG This is a test.")
+}
+
+// TestSetContainerWhileWriting -
+func TestSetContainerWhileWriting(t *testing.T) {
+	writer := NewBlockingWriter()
+	logger := New(LoggerName)
+	logger.AppendWriter(writer, DebugLevel)
+	old := logger.Settings.loadContainer()
+
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		logger.Info("This is a test.")
+	}()
+	for atomic.LoadInt64(&old.refs) != 2 {
+		time.Sleep(time.Millisecond)
+	}
+	logger.SetContainer(NewDefaultContainer(DefaultInitialCapacity))
+	if old.Closed() {
+		t.Error("Expected the previous container to be open while a message is written.")
+	}
+	close(writer.release)
+	<-done
+	if !old.Closed() {
+		t.Error("Expected the previous container to be closed once the message was written.")
+	}
+}
+
+// TestPanickingWriter -
+func TestPanickingWriter(t *testing.T) {
+	logger := New(LoggerName)
+	logger.AppendWriter(PanickingWriter{}, DebugLevel)
+	old := logger.Settings.loadContainer().Container
+	func() {
+		defer func() {
+			recover()
+		}()
+		logger.Info("This is a test.")
+	}()
+
+	logger.SetContainer(NewDefaultContainer(DefaultInitialCapacity))
+	if !old.Closed() {
+		t.Error("Expected the previous container to be closed after a writer panicked.")
+	}
+}
+
+// BenchmarkDisabledLevel -
+func BenchmarkDisabledLevel(b *testing.B) {
+	logger := NewWriters(LoggerName, []io.Writer{ioutil.Discard}, DebugLevel)
+	logger.SetLevel(InfoLevel)
+	b.ReportAllocs()
+	for i := 0; i < b.N; i++ {
+		logger.Debugf("Message %d.", i)
 	}
 }
 
@@ -316,3 +685,33 @@ func (w *MemoryWriter) Clear() {
 	w.Data = nil
 	w.Size = 0
 }
+
+// BasicContainer implements only the methods of the Container interface.
+
+type BasicContainer struct {
+	container *DefaultContainer
+}
+
+func (c *BasicContainer) Append(writer io.Writer, level Level) {
+	c.container.Append(writer, level)
+}
+func (c *BasicContainer) Get(level Level) []*log.Logger {
+	return c.container.Get(level)
+}
+func (c *BasicContainer) Clear() {
+	c.container.Clear()
+}
+func (c *BasicContainer) Close() {
+	c.container.Close()
+}
+func (c *BasicContainer) Closed() bool {
+	return c.container.Closed()
+}
+
+// PanickingWriter -
+
+type PanickingWriter struct{}
+
+func (PanickingWriter) Write(p []byte) (int, error) {
+	panic("write failed")
+}
@@ -0,0 +1,118 @@
+package xlog
+
+import (
+	"bytes"
+	"strconv"
+	"strings"
+	"unicode/utf8"
+)
+
+// shortLevels maps the names of the levels to their abbreviations.
+var shortLevels = map[string]string{
+	"DEBUG":     "DBG",
+	"INFO":      "INF",
+	"NOTICE":    "NOT",
+	"WARNING":   "WRN",
+	"ERROR":     "ERR",
+	"CRITICAL":  "CRT",
+	"ALERT":     "ALR",
+	"EMERGENCY": "EMR",
+}
+
+// modifier changes the value of a placeholder.
+type modifier func(value string) string
+
+// parseModifier returns the modifier named by str, which is one of:
+//
+//	N       pads the value with spaces to N characters, aligned right
+//	-N      pads the value with spaces to N characters, aligned left
+//	lower   converts the value to lower case
+//	upper   converts the value to upper case
+//	short   abbreviates level names to three letters, e.g. WRN
+//	max=N   truncates the value to N characters
+//	json    encodes the value as a quoted JSON string
+//
+// False is returned when str does not name a modifier.
+func parseModifier(str string) (modifier, bool) {
+	switch str {
+	case "lower":
+		return strings.ToLower, true
+	case "upper":
+		return strings.ToUpper, true
+	case "short":
+		return shortLevel, true
+	case "json":
+		return jsonString, true
+	}
+
+	if strings.HasPrefix(str, "max=") {
+		max, err := strconv.Atoi(str[len("max="):])
+		if err != nil || max < 0 {
+			return nil, false
+		}
+		return func(value string) string {
+			return truncate(value, max)
+		}, true
+	}
+	if width, err := strconv.Atoi(str); err == nil && width != 0 {
+		return func(value string) string {
+			return pad(value, width)
+		}, true
+	}
+
+	return nil, false
+}
+
+// shortLevel returns the abbreviation of a level name, in the case of the
+// name. Other values are returned unchanged.
+func shortLevel(value string) string {
+	short, ok := shortLevels[strings.ToUpper(value)]
+	if !ok {
+		return value
+	}
+	if value == strings.ToLower(value) {
+		return strings.ToLower(short)
+	}
+
+	return short
+}
+
+// jsonString returns the value encoded as a quoted JSON string.
+func jsonString(value string) string {
+	buf := &bytes.Buffer{}
+	writeJSON(buf, value)
+	return buf.String()
+}
+
+// truncate returns at most the first max characters of the value.
+func truncate(value string, max int) string {
+	if utf8.RuneCountInString(value) <= max {
+		return value
+	}
+	for i := range value {
+		if max == 0 {
+			return value[:i]
+		}
+		max--
+	}
+
+	return value
+}
+
+// pad pads the value with spaces to the absolute value of width characters.
+// The value is aligned left when width is negative, and right otherwise.
+func pad(value string, width int) string {
+	left := width < 0
+	if left {
+		width = -width
+	}
+	n := width - utf8.RuneCountInString(value)
+	if n <= 0 {
+		return value
+	}
+	if left {
+		return value + strings.Repeat(" ", n)
+	}
+
+	return strings.Repeat(" ", n) + value
+}
@@ -0,0 +1,235 @@
+package xlog
+
+import (
+	"bytes"
+	"errors"
+	"io/ioutil"
+	"os"
+	"os/signal"
+	"sync"
+	"time"
+)
+
+// DefaultReloadInterval is the interval at which a Reloader checks the
+// configuration file for changes.
+const DefaultReloadInterval = 5 * time.Second
+
+// Reloader loads a configuration file with LoadConfig, and loads it again when
+// the file changes, or the process receives one of the Signals. The new
+// configuration replaces the formatters, containers and levels of the running
+// loggers at once, and the containers which are no longer used are closed
+// once the messages being written to them have been written.
+//
+// A logger which is removed from the configuration inherits its settings
+// again. A configuration which cannot be loaded is reported to ErrorFunc,
+// and the previous configuration is kept. An empty file is not loaded, and a
+// changed file is only loaded once it has been left unchanged for an
+// Interval, so a file being written is not loaded. Replacing the file with
+// an atomic rename avoids loading a partly written file altogether.
+type Reloader struct {
+	// Path is the configuration file.
+	Path string
+
+	// Interval is the interval at which the file is checked for changes. The
+	// file is not checked when zero.
+	Interval time.Duration
+
+	// Signals are the signals which cause the file to be loaded again. The
+	// default is SIGHUP, on the platforms which have it.
+	Signals []os.Signal
+
+	// ErrorFunc is called with the error when the file cannot be loaded in the
+	// background. Errors are ignored when nil.
+	ErrorFunc func(error)
+
+	// mu serializes the loads.
+	mu sync.Mutex
+
+	// data is the contents of the last file which was loaded.
+	data []byte
+
+	// loaded describes the file when it was last loaded.
+	loaded fileState
+
+	// checked describes the file when it was last checked for changes.
+	checked fileState
+
+	// names are the loggers configured by the last file which was loaded.
+	names map[string]bool
+
+	// stop is closed to stop the background goroutine.
+	stop chan struct{}
+
+	// done is closed when the background goroutine exits.
+	done chan struct{}
+}
+
+// fileState describes a file which is checked for changes.
+type fileState struct {
+	// modTime is the modification time of the file.
+	modTime time.Time
+
+	// size is the size of the file.
+	size int64
+}
+
+// newFileState returns the fileState of the file.
+func newFileState(info os.FileInfo) fileState {
+	return fileState{info.ModTime(), info.Size()}
+}
+
+// equal returns whether the states are the same.
+func (s fileState) equal(other fileState) bool {
+	return s.modTime.Equal(other.modTime) && s.size == other.size
+}
+
+// NewReloader creates and returns a new *Reloader instance for the file, which
+// checks the file at DefaultReloadInterval, and loads it again on SIGHUP, on
+// the platforms which have it.
+func NewReloader(path string) *Reloader {
+	return &Reloader{
+		Path:     path,
+		Interval: DefaultReloadInterval,
+		Signals:  defaultReloadSignals,
+	}
+}
+
+// Start loads the file, and starts watching it for changes. The error is
+// returned when the file cannot be loaded, and the file is not watched.
+func (r *Reloader) Start() error {
+	if err := r.Reload(); err != nil {
+		return err
+	}
+
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if r.stop != nil {
+		return nil
+	}
+	r.stop = make(chan struct{})
+	r.done = make(chan struct{})
+	go r.run(r.stop, r.done)
+
+	return nil
+}
+
+// Reload loads the file when its contents have changed since it was last
+// loaded. An error is returned when the file is empty.
+func (r *Reloader) Reload() error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	info, err := os.Stat(r.Path)
+	if err != nil {
+		return &ConfigError{File: r.Path, Err: err}
+	}
+	r.loaded = newFileState(info)
+	r.checked = r.loaded
+	data, err := ioutil.ReadFile(r.Path)
+	if err != nil {
+		return &ConfigError{File: r.Path, Err: err}
+	}
+	if len(bytes.TrimSpace(data)) == 0 {
+		return &ConfigError{File: r.Path, Err: errors.New("empty configuration")}
+	}
+	if r.names != nil && bytes.Equal(data, r.data) {
+		return nil
+	}
+
+	cfg, err := parseConfig(data, configFormat(r.Path))
+	if err != nil {
+		if e, ok := err.(*ConfigError); ok {
+			e.File = r.Path
+		}
+		return err
+	}
+
+	names := make(map[string]bool, len(cfg.loggers))
+	for name := range cfg.loggers {
+		names[name] = true
+	}
+	for name := range r.names {
+		if !names[name] {
+			cfg.loggers[name] = &loggerConfig{}
+		}
+	}
+	if err := cfg.apply(); err != nil {
+		if e, ok := err.(*ConfigError); ok {
+			e.File = r.Path
+		}
+		return err
+	}
+	r.data, r.names = data, names
+
+	return nil
+}
+
+// Close stops watching the file. The loggers keep their configuration.
+func (r *Reloader) Close() {
+	r.mu.Lock()
+	stop, done := r.stop, r.done
+	r.stop, r.done = nil, nil
+	r.mu.Unlock()
+
+	if stop != nil {
+		close(stop)
+		<-done
+	}
+}
+
+// run checks the file at each interval, and loads it on each signal, until
+// stop is closed.
+func (r *Reloader) run(stop, done chan struct{}) {
+	defer close(done)
+
+	var signals chan os.Signal
+	if len(r.Signals) > 0 {
+		signals = make(chan os.Signal, 1)
+		signal.Notify(signals, r.Signals...)
+		defer signal.Stop(signals)
+	}
+	var tick <-chan time.Time
+	if r.Interval > 0 {
+		ticker := time.NewTicker(r.Interval)
+		defer ticker.Stop()
+		tick = ticker.C
+	}
+
+	for {
+		select {
+		case <-stop:
+			return
+		case <-signals:
+			r.report(r.Reload())
+		case <-tick:
+			if r.changed() {
+				r.report(r.Reload())
+			}
+		}
+	}
+}
+
+// changed returns whether the modification time or size of the file has
+// changed since it was last loaded, and they have not changed since it was
+// last checked, so the file is not being written.
+func (r *Reloader) changed() bool {
+	info, err := os.Stat(r.Path)
+	if err != nil {
+		return false
+	}
+
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	state := newFileState(info)
+	settled := state.equal(r.checked)
+	r.checked = state
+
+	return settled && !state.equal(r.loaded)
+}
+
+// report passes a non-nil error to ErrorFunc.
+func (r *Reloader) report(err error) {
+	if err != nil && r.ErrorFunc != nil {
+		r.ErrorFunc(err)
+	}
+}
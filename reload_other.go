@@ -0,0 +1,9 @@
+//go:build js || wasip1
+// +build js wasip1
+
+package xlog
+
+import "os"
+
+// defaultReloadSignals is empty, because there is no SIGHUP on this platform.
+var defaultReloadSignals []os.Signal
@@ -0,0 +1,13 @@
+//go:build !js && !wasip1
+// +build !js,!wasip1
+
+package xlog
+
+import (
+	"os"
+	"syscall"
+)
+
+// defaultReloadSignals are the signals which cause a Reloader to load the file
+// again by default.
+var defaultReloadSignals = []os.Signal{syscall.SIGHUP}
@@ -0,0 +1,160 @@
+package xlog
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+// WriteFileAtomic writes the file to a temporary file, which is renamed to the
+// file, so the file is never seen partly written.
+func WriteFileAtomic(t *testing.T, name string, data []byte) {
+	tmp := name + ".tmp"
+	if err := ioutil.WriteFile(tmp, data, 0644); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.Rename(tmp, name); err != nil {
+		t.Fatal(err)
+	}
+}
+
+// TestReloader -
+func TestReloader(t *testing.T) {
+	dir := t.TempDir()
+	first, second := filepath.Join(dir, "first.log"), filepath.Join(dir, "second.log")
+	name := filepath.Join(dir, "xlog.yaml")
+	app, db := UniqueName("reload.app"), UniqueName("reload.db")
+	config := func(path string) {
+		data := "loggers:\n  " + app + ":\n    format: \"{message}\"\n    outputs:\n      - " + path + "\n  " + db + ":\n    level: error\n"
+		WriteFileAtomic(t, name, []byte(data))
+	}
+	config(first)
+
+	r := NewReloader(name)
+	r.Interval = 10 * time.Millisecond
+	r.Signals = nil
+	r.ErrorFunc = func(err error) {
+		t.Error(err)
+	}
+	if err := r.Start(); err != nil {
+		t.Fatal(err)
+	}
+	defer r.Close()
+	logger := GetLogger(app)
+	old := logger.Settings.loadContainer().Container
+
+	var written int64
+	var wg sync.WaitGroup
+	stop := make(chan struct{})
+	for i := 0; i < 4; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for {
+				select {
+				case <-stop:
+					return
+				default:
+					logger.Info("line")
+					atomic.AddInt64(&written, 1)
+				}
+			}
+		}()
+	}
+
+	time.Sleep(20 * time.Millisecond)
+	config(second)
+	for deadline := time.Now().Add(5 * time.Second); !old.Closed(); {
+		if time.Now().After(deadline) {
+			t.Fatal("Expected the configuration to be reloaded.")
+		}
+		time.Sleep(5 * time.Millisecond)
+	}
+	time.Sleep(20 * time.Millisecond)
+	close(stop)
+	wg.Wait()
+	logger.Close()
+
+	a, _ := ioutil.ReadFile(first)
+	b, _ := ioutil.ReadFile(second)
+	lines := strings.Count(string(a), "line\n") + strings.Count(string(b), "line\n")
+	if int64(lines) != atomic.LoadInt64(&written) || len(b) == 0 {
+		t.Errorf("Expected %d lines but got %d.", atomic.LoadInt64(&written), lines)
+	}
+}
+
+// TestReloaderRemovedLogger -
+func TestReloaderRemovedLogger(t *testing.T) {
+	name := filepath.Join(t.TempDir(), "xlog.json")
+	ioutil.WriteFile(name, []byte(`{"loggers": {"reload.removed": {"level": "error"}}}`), 0644)
+	r := NewReloader(name)
+	if err := r.Reload(); err != nil {
+		t.Fatal(err)
+	}
+	if GetLogger("reload.removed").Level() != ErrorLevel {
+		t.Fatal("Expected the level to be configured.")
+	}
+
+	ioutil.WriteFile(name, []byte(`{"loggers": {}}`), 0644)
+	if err := r.Reload(); err != nil {
+		t.Fatal(err)
+	}
+	if GetLogger("reload.removed").Level() != Instance().Level() {
+		t.Error("Expected the level to be inherited once removed from the configuration.")
+	}
+
+	ioutil.WriteFile(name, []byte(`{"level": "verbose"}`), 0644)
+	if err := r.Reload(); err == nil {
+		t.Error("Expected an error for an invalid configuration.")
+	}
+}
+
+// TestReloaderEmptyFile -
+func TestReloaderEmptyFile(t *testing.T) {
+	name := filepath.Join(t.TempDir(), "xlog.yaml")
+	logger := UniqueName("reload.empty")
+	ioutil.WriteFile(name, []byte("loggers:\n  "+logger+":\n    level: error\n"), 0644)
+	r := NewReloader(name)
+	if err := r.Reload(); err != nil {
+		t.Fatal(err)
+	}
+
+	for _, data := range []string{"", " \n"} {
+		ioutil.WriteFile(name, []byte(data), 0644)
+		if err := r.Reload(); err == nil {
+			t.Errorf("Expected an error for the empty configuration %q.", data)
+		}
+		if GetLogger(logger).IsEnabled(DebugLevel) {
+			t.Error("Expected the configuration to be kept.")
+		}
+	}
+}
+
+// TestReloaderChanged -
+func TestReloaderChanged(t *testing.T) {
+	name := filepath.Join(t.TempDir(), "xlog.yaml")
+	ioutil.WriteFile(name, []byte("level: error\n"), 0644)
+	r := NewReloader(name)
+	if r.changed() {
+		t.Error("Expected the file to be loaded once it's unchanged for an interval.")
+	}
+	if !r.changed() {
+		t.Error("Expected the file to be loaded once it's unchanged.")
+	}
+}
+
+// TestSetContainer -
+func TestSetContainer(t *testing.T) {
+	logger, _ := LoggerFixture(DebugLevel)
+	old := logger.Settings.loadContainer().Container
+	container := NewDefaultContainer(DefaultInitialCapacity)
+	logger.SetContainer(container)
+	if !old.Closed() || container.Closed() {
+		t.Error("Expected the previous container to be closed.")
+	}
+}
@@ -0,0 +1,359 @@
+package xlog
+
+import (
+	"compress/gzip"
+	"io"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"regexp"
+	"sort"
+	"strings"
+	"time"
+)
+
+const (
+	// gzipExt is the extension added to compressed files.
+	gzipExt = ".gz"
+
+	// tempExt is the extension of files which are being compressed.
+	tempExt = ".gz.tmp"
+
+	// staleTempAge is the age at which a temporary file is considered to have
+	// been left behind by a crash.
+	staleTempAge = time.Minute
+)
+
+// compressJob describes a rotated file being compressed.
+type compressJob struct {
+	// name is the current name of the rotated file, which changes when the
+	// backups are shifted during compression.
+	name string
+
+	// removed defines whether the rotated file was removed during compression.
+	removed bool
+}
+
+// postProcess returns whether rotated files are compressed or pruned.
+func (r *RotatingFile) postProcess() bool {
+	return r.Compress || r.MaxAge > 0 || r.MaxTotalSize > 0 || (r.dated && r.MaxBackups > 0)
+}
+
+// schedule wakes the background goroutine, starting it when needed. It must
+// be called with r.mu held.
+func (r *RotatingFile) schedule() {
+	if r.closed || !r.postProcess() {
+		return
+	}
+	if r.wake == nil {
+		r.wake = make(chan struct{}, 1)
+		r.done = make(chan struct{})
+		go r.run()
+	}
+
+	select {
+	case r.wake <- struct{}{}:
+	default:
+	}
+}
+
+// run compresses and prunes rotated files each time the goroutine is woken,
+// until the wake channel is closed. The files are listed without holding
+// r.mu, which is only held to coordinate with shift, so writes are not
+// blocked by the file system.
+func (r *RotatingFile) run() {
+	defer close(r.done)
+	for range r.wake {
+		r.cleanTemp()
+		if r.Compress {
+			for r.compressNext() {
+			}
+		}
+		r.prune()
+	}
+}
+
+// cleanTemp removes temporary files left behind when compression was
+// interrupted by a crash. The files are checked each time the goroutine is
+// woken, as the files left behind by a recent crash are not stale yet.
+func (r *RotatingFile) cleanTemp() {
+	current, _ := r.snapshot()
+	pattern := filepath.Join(filepath.Dir(current), "."+filepath.Base(r.globPattern())+tempExt)
+	names, _ := filepath.Glob(pattern)
+	for _, name := range names {
+		if !r.isRotated(tempSource(name), current) {
+			continue
+		}
+		info, err := os.Stat(name)
+		if err == nil && time.Since(info.ModTime()) > staleTempAge {
+			r.report(os.Remove(name))
+		}
+	}
+}
+
+// compressNext compresses one rotated file, and returns whether a file was
+// found to compress.
+func (r *RotatingFile) compressNext() bool {
+	current, shifts := r.snapshot()
+	name := ""
+	for _, rotated := range r.rotated(current) {
+		if !strings.HasSuffix(rotated, gzipExt) {
+			name = rotated
+			break
+		}
+	}
+	if name == "" {
+		return false
+	}
+
+	r.mu.Lock()
+	if r.shifts != shifts {
+		// The backups were shifted after they were listed, so they are
+		// listed again.
+		r.mu.Unlock()
+		return true
+	}
+	src, err := os.Open(name)
+	if err == nil {
+		r.job = &compressJob{name: name}
+	}
+	r.mu.Unlock()
+	if err != nil {
+		r.report(err)
+		return false
+	}
+
+	tmp, err := r.compress(src)
+	src.Close()
+
+	r.mu.Lock()
+	job := r.job
+	r.job = nil
+	if err == nil && job.removed {
+		err = os.Remove(tmp)
+	} else if err == nil {
+		if err = os.Rename(tmp, job.name+gzipExt); err == nil {
+			err = os.Remove(job.name)
+		}
+	}
+	r.mu.Unlock()
+
+	if err != nil {
+		r.report(err)
+		if tmp != "" {
+			os.Remove(tmp)
+		}
+		return false
+	}
+	return true
+}
+
+// compress writes a gzip copy of the file to a temporary file in the same
+// directory, and returns the name of the temporary file.
+func (r *RotatingFile) compress(src *os.File) (string, error) {
+	base := filepath.Base(src.Name())
+	dst, err := ioutil.TempFile(filepath.Dir(src.Name()), "."+base+".*"+tempExt)
+	if err != nil {
+		return "", err
+	}
+	if info, err := src.Stat(); err == nil {
+		dst.Chmod(info.Mode())
+	}
+
+	zw, _ := gzip.NewWriterLevel(dst, gzip.DefaultCompression)
+	zw.Name = base
+	_, err = io.Copy(zw, src)
+	if err == nil {
+		err = zw.Close()
+	}
+	if err == nil {
+		err = dst.Sync()
+	}
+	if closeErr := dst.Close(); err == nil {
+		err = closeErr
+	}
+
+	return dst.Name(), err
+}
+
+// prune removes the rotated files which go over MaxBackups, MaxTotalSize or
+// MaxAge, removing the oldest files first.
+func (r *RotatingFile) prune() {
+	if r.MaxBackups <= 0 && r.MaxAge <= 0 && r.MaxTotalSize <= 0 {
+		return
+	}
+
+	var errs []error
+	defer func() {
+		for _, err := range errs {
+			r.report(err)
+		}
+	}()
+
+	current, shifts := r.snapshot()
+	names := r.rotated(current)
+	infos := make([]os.FileInfo, 0, len(names))
+	paths := make(map[os.FileInfo]string, len(names))
+	for _, name := range names {
+		if info, err := os.Stat(name); err == nil {
+			infos = append(infos, info)
+			paths[info] = name
+		}
+	}
+	sort.Slice(infos, func(i, j int) bool {
+		return infos[i].ModTime().After(infos[j].ModTime())
+	})
+
+	cutoff := r.now().Add(-r.MaxAge)
+	var total int64
+	var removed []string
+	for count, info := range infos {
+		total += info.Size()
+		if (r.MaxBackups > 0 && count >= r.MaxBackups) ||
+			(r.MaxTotalSize > 0 && total > r.MaxTotalSize) ||
+			(r.MaxAge > 0 && info.ModTime().Before(cutoff)) {
+			removed = append(removed, paths[info])
+		}
+	}
+	if len(removed) == 0 {
+		return
+	}
+
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if r.shifts != shifts {
+		// The backups were shifted after they were listed, and the rotation
+		// which shifted them wakes the goroutine again.
+		return
+	}
+	for _, name := range removed {
+		if err := os.Remove(name); err != nil {
+			errs = append(errs, err)
+		}
+	}
+}
+
+// snapshot returns the name of the file being written, and the number of
+// times the backups have been shifted, which tells whether a list of the
+// rotated files is still valid.
+func (r *RotatingFile) snapshot() (string, int) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	return r.name, r.shifts
+}
+
+// rotated returns the names of the rotated files, which includes compressed
+// files, given the name of the file being written.
+func (r *RotatingFile) rotated(current string) []string {
+	names, _ := filepath.Glob(r.globPattern())
+	rotated := make([]string, 0, len(names))
+	for _, name := range names {
+		if r.isRotated(name, current) {
+			rotated = append(rotated, name)
+		}
+	}
+
+	return rotated
+}
+
+// isRotated returns whether the named file is one of the rotated files, which
+// are the backups of the file, such as "app.log.1" and "app.log.1.gz", and
+// for a dated file name, the files of the previous dates, such as
+// "app-2026-10-15.log", and their backups. A dated file only matches when
+// its dates are parsed by the layouts of the file name, so the files of other
+// loggers in the same directory don't match. The current name is the name of
+// the file being written.
+func (r *RotatingFile) isRotated(name, current string) bool {
+	name = strings.TrimSuffix(name, gzipExt)
+	if name != current && r.isDatedName(name) {
+		return true
+	}
+	i := strings.LastIndexByte(name, '.')
+	if i < 0 || !isBackupNumber(name[i+1:]) {
+		return false
+	}
+	name = name[:i]
+
+	return name == current || r.isDatedName(name)
+}
+
+// isDatedName returns whether the name is the file name formatted at some
+// date, when the file name contains date placeholders.
+func (r *RotatingFile) isDatedName(name string) bool {
+	if !r.dated {
+		return false
+	}
+	match := r.nameRegexp.FindStringSubmatch(name)
+	if match == nil {
+		return false
+	}
+	for i, layout := range dateRegexp.FindAllStringSubmatch(r.pattern, -1) {
+		if _, err := time.Parse(layout[1], match[i+1]); err != nil {
+			return false
+		}
+	}
+
+	return true
+}
+
+// nameRegexp returns a regular expression matching the file names formatted
+// from the pattern, which captures the text of each date placeholder.
+func nameRegexp(pattern string) *regexp.Regexp {
+	var buf strings.Builder
+	buf.WriteByte('^')
+	last := 0
+	for _, loc := range dateRegexp.FindAllStringIndex(pattern, -1) {
+		buf.WriteString(regexp.QuoteMeta(pattern[last:loc[0]]))
+		buf.WriteString("(.+?)")
+		last = loc[1]
+	}
+	buf.WriteString(regexp.QuoteMeta(pattern[last:]))
+	buf.WriteByte('$')
+
+	return regexp.MustCompile(buf.String())
+}
+
+// isBackupNumber returns whether the extension is the number of a backup.
+func isBackupNumber(ext string) bool {
+	if ext == "" {
+		return false
+	}
+	for _, c := range ext {
+		if c < '0' || c > '9' {
+			return false
+		}
+	}
+
+	return true
+}
+
+// tempSource returns the name of the rotated file a temporary file was
+// compressing, given the name of the temporary file.
+func tempSource(name string) string {
+	base := strings.TrimSuffix(strings.TrimPrefix(filepath.Base(name), "."), tempExt)
+	if i := strings.LastIndexByte(base, '.'); i >= 0 {
+		base = base[:i]
+	}
+
+	return filepath.Join(filepath.Dir(name), base)
+}
+
+// globPattern returns a pattern matching the file and its rotated files,
+// along with the files of other loggers, which isRotated excludes.
+func (r *RotatingFile) globPattern() string {
+	pattern := r.pattern
+	if r.dated {
+		pattern = dateRegexp.ReplaceAllString(pattern, "*")
+		return pattern + "*"
+	}
+
+	return pattern + ".*"
+}
+
+// report passes a non-nil error to ErrorFunc. It must not be called with
+// r.mu held, so ErrorFunc may log the error.
+func (r *RotatingFile) report(err error) {
+	if err != nil && r.ErrorFunc != nil {
+		r.ErrorFunc(err)
+	}
+}
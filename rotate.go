@@ -0,0 +1,367 @@
+package xlog
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"regexp"
+	"sync"
+	"time"
+)
+
+// RotatingFile is an io.WriteCloser which writes to a file, and rotates the
+// file once it reaches a maximum size. The current file is renamed by adding
+// the suffix ".1", and any existing backups are shifted up by one, so
+// "app.log.1" becomes "app.log.2", and so on. A new file is then opened with
+// the original name.
+//
+// The file name may also contain {date|layout} placeholders, e.g.
+// "/var/log/app-{date|2006-01-02}.log", in which case a new file is started
+// whenever the formatted name changes. A layout of "2006-01-02" starts a new
+// file each day, and "2006-01-02-15" each hour. Missing parent directories
+// are created when the file is opened.
+//
+// Rotated files may be compressed with gzip, and pruned by age, count and
+// total size. This work is done by a background goroutine, so writes are
+// never blocked by it. RotatingFile is safe for concurrent use.
+type RotatingFile struct {
+	// MaxSize is the size in bytes at which the file is rotated. The file is
+	// never rotated when MaxSize is zero.
+	MaxSize int64
+
+	// MaxBackups is the maximum number of rotated files to keep. All rotated
+	// files are kept when MaxBackups is zero.
+	MaxBackups int
+
+	// FileOpenFlags defines the file open options.
+	FileOpenFlags int
+
+	// FileOpenMode defines the mode files are opened in.
+	FileOpenMode os.FileMode
+
+	// Location is the time zone used to format dates in the file name. The
+	// local time zone is used when nil.
+	Location *time.Location
+
+	// Compress defines whether rotated files are compressed with gzip.
+	Compress bool
+
+	// MaxAge is the maximum age of rotated files. Rotated files are not
+	// removed because of their age when MaxAge is zero.
+	MaxAge time.Duration
+
+	// MaxTotalSize is the maximum number of bytes used by all rotated files.
+	// The oldest files are removed first. Rotated files are not removed because
+	// of their size when MaxTotalSize is zero.
+	MaxTotalSize int64
+
+	// ErrorFunc is called with any error that happens while compressing or
+	// removing rotated files. Errors are ignored when nil.
+	ErrorFunc func(error)
+
+	// pattern is the file name, which may contain date placeholders.
+	pattern string
+
+	// dated defines whether the pattern contains date placeholders.
+	dated bool
+
+	// nameRegexp matches the file names formatted from the pattern, when it
+	// contains date placeholders.
+	nameRegexp *regexp.Regexp
+
+	// name is the path to the file being written.
+	name string
+
+	// rollover is the time at which the formatted name changes, when the
+	// pattern contains date placeholders.
+	rollover time.Time
+
+	// now returns the current time.
+	now func() time.Time
+
+	// mu guards the fields below.
+	mu sync.Mutex
+
+	// file is the open file, or nil when the file has not been opened.
+	file *os.File
+
+	// size is the number of bytes in the open file.
+	size int64
+
+	// closed defines whether the file has been closed.
+	closed bool
+
+	// wake signals the background goroutine to process rotated files.
+	wake chan struct{}
+
+	// done is closed when the background goroutine exits.
+	done chan struct{}
+
+	// job is the file being compressed by the background goroutine.
+	job *compressJob
+
+	// shifts is the number of times the backups have been shifted.
+	shifts int
+}
+
+// NewRotatingFile creates and returns a *RotatingFile instance. The file is
+// opened by calling Open, or by the first call to Write.
+func NewRotatingFile(pattern string, maxSize int64, maxBackups int) *RotatingFile {
+	r := &RotatingFile{
+		MaxSize:       maxSize,
+		MaxBackups:    maxBackups,
+		FileOpenFlags: DefaultFileOpenFlags,
+		FileOpenMode:  DefaultFileOpenMode,
+		pattern:       pattern,
+		dated:         IsDatePattern(pattern),
+		name:          pattern,
+		now:           time.Now,
+	}
+	if r.dated {
+		r.nameRegexp = nameRegexp(pattern)
+	}
+
+	return r
+}
+
+// Name returns the path to the file being written.
+func (r *RotatingFile) Name() string {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if r.file == nil && r.dated {
+		return r.nameAt(r.now())
+	}
+
+	return r.name
+}
+
+// Open opens the file when it's not already open.
+func (r *RotatingFile) Open() error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if r.closed {
+		return os.ErrClosed
+	}
+	if r.file != nil {
+		return nil
+	}
+
+	return r.open()
+}
+
+// Write implements io.Writer.Write. The file is rotated before writing when
+// the write would take the file over MaxSize.
+func (r *RotatingFile) Write(p []byte) (int, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if r.closed {
+		return 0, os.ErrClosed
+	}
+	if r.dated && r.file != nil && !r.now().Before(r.rollover) {
+		err := r.file.Close()
+		r.file = nil
+		if err != nil {
+			return 0, err
+		}
+	}
+	if r.file == nil {
+		if err := r.open(); err != nil {
+			return 0, err
+		}
+	}
+	if r.MaxSize > 0 && r.size > 0 && r.size+int64(len(p)) > r.MaxSize {
+		if err := r.rotate(); err != nil {
+			return 0, err
+		}
+	}
+
+	n, err := r.file.Write(p)
+	r.size += int64(n)
+	return n, err
+}
+
+// Rotate rotates the file regardless of its size.
+func (r *RotatingFile) Rotate() error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if r.closed {
+		return os.ErrClosed
+	}
+	if r.file == nil {
+		if err := r.open(); err != nil {
+			return err
+		}
+	}
+
+	return r.rotate()
+}
+
+// Close implements io.Closer.Close. Close waits for the background
+// goroutine to finish processing rotated files.
+func (r *RotatingFile) Close() error {
+	r.mu.Lock()
+	if r.closed {
+		r.mu.Unlock()
+		return nil
+	}
+	r.closed = true
+
+	var err error
+	if r.file != nil {
+		err = r.file.Close()
+		r.file = nil
+	}
+	done := r.done
+	if r.wake != nil {
+		close(r.wake)
+	}
+	r.mu.Unlock()
+
+	if done != nil {
+		<-done
+	}
+	return err
+}
+
+// open opens the file and records its current size. The file name is
+// formatted with the current date when the pattern contains date placeholders.
+func (r *RotatingFile) open() error {
+	if r.dated {
+		now := r.now()
+		r.name = r.nameAt(now)
+		r.rollover = r.nextRollover(now)
+	}
+	if err := os.MkdirAll(filepath.Dir(r.name), dirMode(r.FileOpenMode)); err != nil {
+		return err
+	}
+	file, err := os.OpenFile(r.name, r.FileOpenFlags, r.FileOpenMode)
+	if err != nil {
+		return err
+	}
+	info, err := file.Stat()
+	if err != nil {
+		file.Close()
+		return err
+	}
+
+	r.file = file
+	r.size = info.Size()
+	r.schedule()
+	return nil
+}
+
+// rotate renames the open file to the first backup name, and opens a new file
+// in its place.
+func (r *RotatingFile) rotate() error {
+	if err := r.file.Close(); err != nil {
+		return err
+	}
+	r.file = nil
+
+	err := r.shift()
+	if err == nil {
+		err = os.Rename(r.name, r.backupName(1))
+	}
+	if openErr := r.open(); openErr != nil {
+		return openErr
+	}
+
+	return err
+}
+
+// shift moves each backup up by one, removing the backups which go over
+// MaxBackups.
+func (r *RotatingFile) shift() error {
+	r.shifts++
+	last := r.MaxBackups
+	if last <= 0 {
+		for last = 1; exists(r.backupName(last)) || exists(r.backupName(last)+gzipExt); last++ {
+		}
+	}
+	for _, ext := range []string{"", gzipExt} {
+		name := r.backupName(last) + ext
+		if err := os.Remove(name); err != nil && !errors.Is(err, os.ErrNotExist) {
+			return err
+		}
+		if r.job != nil && r.job.name == name {
+			r.job.removed = true
+		}
+	}
+	for i := last - 1; i > 0; i-- {
+		for _, ext := range []string{"", gzipExt} {
+			from, to := r.backupName(i)+ext, r.backupName(i+1)+ext
+			err := os.Rename(from, to)
+			if err != nil && !errors.Is(err, os.ErrNotExist) {
+				return err
+			}
+			if r.job != nil && r.job.name == from {
+				r.job.name = to
+			}
+		}
+	}
+
+	return nil
+}
+
+// backupName returns the name of the nth backup.
+func (r *RotatingFile) backupName(n int) string {
+	return fmt.Sprintf("%s.%d", r.name, n)
+}
+
+// nameAt returns the file name for the given time.
+func (r *RotatingFile) nameAt(t time.Time) string {
+	if r.Location != nil {
+		t = t.In(r.Location)
+	}
+
+	return dateRegexp.ReplaceAllStringFunc(r.pattern, func(placeholder string) string {
+		return t.Format(dateRegexp.FindStringSubmatch(placeholder)[1])
+	})
+}
+
+// nextRollover returns the first time after t at which the formatted name
+// changes. Names can only change when a second, minute, hour, day, month or
+// year starts, so the start of each is checked in turn, and the first which
+// changes the name is the next rollover. The start of the next year is
+// returned when none changes the name.
+func (r *RotatingFile) nextRollover(t time.Time) time.Time {
+	if r.Location != nil {
+		t = t.In(r.Location)
+	}
+	name := r.nameAt(t)
+	year, month, day := t.Date()
+	hour, min, sec := t.Clock()
+	loc := t.Location()
+	starts := []time.Time{
+		time.Date(year, month, day, hour, min, sec+1, 0, loc),
+		time.Date(year, month, day, hour, min+1, 0, 0, loc),
+		time.Date(year, month, day, hour+1, 0, 0, 0, loc),
+		time.Date(year, month, day+1, 0, 0, 0, 0, loc),
+		time.Date(year, month+1, 1, 0, 0, 0, 0, loc),
+	}
+	for _, start := range starts {
+		if r.nameAt(start) != name {
+			return start
+		}
+	}
+
+	return time.Date(year+1, 1, 1, 0, 0, 0, 0, loc)
+}
+
+// IsDatePattern returns whether the file name contains {date|layout} placeholders.
+func IsDatePattern(name string) bool {
+	return dateRegexp.MatchString(name)
+}
+
+// dirMode returns the mode used to create directories for files opened with
+// the given mode. The execute bit is added wherever the read bit is set.
+func dirMode(mode os.FileMode) os.FileMode {
+	return mode | (mode&0444)>>2
+}
+
+// exists returns whether the named file exists.
+func exists(name string) bool {
+	_, err := os.Lstat(name)
+	return err == nil
+}
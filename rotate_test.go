@@ -0,0 +1,248 @@
+package xlog
+
+import (
+	"compress/gzip"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"reflect"
+	"sort"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+)
+
+// TestRotatingFile -
+func TestRotatingFile(t *testing.T) {
+	name := filepath.Join(t.TempDir(), "app.log")
+	r := NewRotatingFile(name, 10, 2)
+	defer r.Close()
+
+	for _, line := range []string{"aaaaaaaa\n", "bbbbbbbb\n", "cccccccc\n", "dddddddd\n"} {
+		if _, err := r.Write([]byte(line)); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	expected := map[string]string{
+		name:        "dddddddd\n",
+		name + ".1": "cccccccc\n",
+		name + ".2": "bbbbbbbb\n",
+	}
+	for file, data := range expected {
+		actual, err := ioutil.ReadFile(file)
+		if err != nil {
+			t.Fatal(err)
+		}
+		ActualEquals(t, string(actual), data)
+	}
+	if exists(name + ".3") {
+		t.Error("Expected no more than 2 backups.")
+	}
+}
+
+// TestAppendRotating -
+func TestAppendRotating(t *testing.T) {
+	name := filepath.Join(t.TempDir(), "app.log")
+	logger := New(LoggerName)
+	logger.FileMaxSize = 1024
+	logger.Formatter = NewDefaultFormatter("{message}", DefaultDateFormat)
+	logger.Append(name, DebugLevel)
+
+	var wg sync.WaitGroup
+	for i := 0; i < 8; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for j := 0; j < 100; j++ {
+				logger.Info("This is a test.")
+			}
+		}()
+	}
+	wg.Wait()
+	logger.Close()
+
+	files, _ := filepath.Glob(name + "*")
+	lines := 0
+	for _, file := range files {
+		data, err := ioutil.ReadFile(file)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if len(data) > 1024 {
+			t.Errorf("Expected %s to be no larger than 1024 bytes.", file)
+		}
+		lines += strings.Count(string(data), "This is a test.\n")
+	}
+	if lines != 800 {
+		t.Errorf("Expected 800 lines but got %d.", lines)
+	}
+}
+
+// TestRotatingFileDated -
+func TestRotatingFileDated(t *testing.T) {
+	dir := t.TempDir()
+	r := NewRotatingFile(filepath.Join(dir, "logs", "app-{date|2006-01-02}.log"), 0, 0)
+	r.Location = time.UTC
+	defer r.Close()
+
+	now := time.Date(2026, 10, 16, 23, 59, 59, 0, time.UTC)
+	r.now = func() time.Time { return now }
+	r.Write([]byte("first\n"))
+	now = now.Add(time.Second)
+	r.Write([]byte("second\n"))
+
+	expected := map[string]string{
+		"app-2026-10-16.log": "first\n",
+		"app-2026-10-17.log": "second\n",
+	}
+	for file, data := range expected {
+		actual, err := ioutil.ReadFile(filepath.Join(dir, "logs", file))
+		if err != nil {
+			t.Fatal(err)
+		}
+		ActualEquals(t, string(actual), data)
+	}
+	ActualEquals(t, r.Name(), filepath.Join(dir, "logs", "app-2026-10-17.log"))
+}
+
+// TestRotatingFileRollover -
+func TestRotatingFileRollover(t *testing.T) {
+	r := NewRotatingFile("app-{date|2006-01-02T15}.log", 0, 0)
+	r.Location = time.FixedZone("IST", 5*3600+1800)
+
+	now := time.Date(2026, 10, 16, 10, 15, 30, 0, time.UTC)
+	ActualEquals(t, r.nextRollover(now).UTC().Format(time.RFC3339), "2026-10-16T10:30:00Z")
+
+	r = NewRotatingFile("app-{date|Jan}.log", 0, 0)
+	r.Location = time.UTC
+	ActualEquals(t, r.nextRollover(now).UTC().Format(time.RFC3339), "2026-11-01T00:00:00Z")
+}
+
+// TestRotatingFileCompress -
+func TestRotatingFileCompress(t *testing.T) {
+	dir := t.TempDir()
+	name := filepath.Join(dir, "app.log")
+	stale := filepath.Join(dir, ".app.log.1.123"+tempExt)
+	ioutil.WriteFile(stale, []byte("partial"), 0666)
+	os.Chtimes(stale, time.Now().Add(-time.Hour), time.Now().Add(-time.Hour))
+
+	r := NewRotatingFile(name, 10, 2)
+	r.Compress = true
+	r.ErrorFunc = func(err error) {
+		t.Error(err)
+	}
+	for _, line := range []string{"aaaaaaaa\n", "bbbbbbbb\n", "cccccccc\n", "dddddddd\n"} {
+		r.Write([]byte(line))
+	}
+	r.Close()
+
+	expected := map[string]string{
+		name + ".1.gz": "cccccccc\n",
+		name + ".2.gz": "bbbbbbbb\n",
+	}
+	for file, data := range expected {
+		fp, err := os.Open(file)
+		if err != nil {
+			t.Fatal(err)
+		}
+		zr, err := gzip.NewReader(fp)
+		if err != nil {
+			t.Fatal(err)
+		}
+		actual, _ := ioutil.ReadAll(zr)
+		fp.Close()
+		ActualEquals(t, string(actual), data)
+	}
+
+	files, _ := filepath.Glob(filepath.Join(dir, "*"))
+	hidden, _ := filepath.Glob(filepath.Join(dir, ".*"))
+	if len(files) != 3 || len(hidden) != 0 {
+		t.Errorf("Expected 3 files but got %v %v.", files, hidden)
+	}
+}
+
+// TestRotatingFileStaleTemp -
+func TestRotatingFileStaleTemp(t *testing.T) {
+	dir := t.TempDir()
+	name := filepath.Join(dir, "app.log")
+	r := NewRotatingFile(name, 0, 0)
+	r.Compress = true
+	r.Write([]byte("aaaaaaaa\n"))
+
+	stale := filepath.Join(dir, ".app.log.1.123"+tempExt)
+	ioutil.WriteFile(stale, []byte("partial"), 0666)
+	os.Chtimes(stale, time.Now().Add(-time.Hour), time.Now().Add(-time.Hour))
+	r.Rotate()
+	r.Close()
+
+	if exists(stale) {
+		t.Error("Expected the stale temporary file to be removed once the file was rotated.")
+	}
+}
+
+// TestRotatingFileRetention -
+func TestRotatingFileRetention(t *testing.T) {
+	dir := t.TempDir()
+	for i, day := range []string{"10", "11", "12", "13"} {
+		old := filepath.Join(dir, "app-2026-10-"+day+".log")
+		ioutil.WriteFile(old, []byte("old\n"), 0666)
+		mtime := time.Date(2026, 10, 10+i, 23, 0, 0, 0, time.UTC)
+		os.Chtimes(old, mtime, mtime)
+	}
+
+	r := NewRotatingFile(filepath.Join(dir, "app-{date|2006-01-02}.log"), 0, 0)
+	r.Location = time.UTC
+	r.MaxAge = 72 * time.Hour
+	r.now = func() time.Time { return time.Date(2026, 10, 16, 12, 0, 0, 0, time.UTC) }
+	r.Write([]byte("new\n"))
+	r.Close()
+
+	files, _ := filepath.Glob(filepath.Join(dir, "*"))
+	expected := []string{
+		filepath.Join(dir, "app-2026-10-13.log"),
+		filepath.Join(dir, "app-2026-10-16.log"),
+	}
+	if !reflect.DeepEqual(files, expected) {
+		t.Errorf("Expected %v but got %v.", expected, files)
+	}
+}
+
+// TestRotatingFileOtherFiles -
+func TestRotatingFileOtherFiles(t *testing.T) {
+	dir := t.TempDir()
+	others := []string{
+		filepath.Join(dir, "app-worker-2026-10-15.log"),
+		filepath.Join(dir, "app-worker-2026-10-16.log"),
+		filepath.Join(dir, "app.log.old"),
+		filepath.Join(dir, "app.logs.1"),
+	}
+	for _, name := range append(others, filepath.Join(dir, "app-2026-10-15.log")) {
+		ioutil.WriteFile(name, []byte("old\n"), 0666)
+	}
+
+	dated := NewRotatingFile(filepath.Join(dir, "app-{date|2006-01-02}.log"), 0, 1)
+	dated.Location = time.UTC
+	dated.Compress = true
+	dated.now = func() time.Time { return time.Date(2026, 10, 16, 12, 0, 0, 0, time.UTC) }
+	dated.Write([]byte("new\n"))
+	dated.Close()
+	plain := NewRotatingFile(filepath.Join(dir, "app.log"), 4, 1)
+	plain.Compress = true
+	plain.Write([]byte("new\n"))
+	plain.Write([]byte("new\n"))
+	plain.Close()
+
+	files, _ := filepath.Glob(filepath.Join(dir, "*"))
+	expected := append([]string{
+		filepath.Join(dir, "app-2026-10-15.log.gz"),
+		filepath.Join(dir, "app-2026-10-16.log"),
+		filepath.Join(dir, "app.log"),
+		filepath.Join(dir, "app.log.1.gz"),
+	}, others...)
+	sort.Strings(expected)
+	if !reflect.DeepEqual(files, expected) {
+		t.Errorf("Expected %v but got %v.", expected, files)
+	}
+}
@@ -0,0 +1,342 @@
+package xlog
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"net"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// DefaultSyslogTimeout is the time a SyslogWriter waits to connect, or to
+// write a message.
+const DefaultSyslogTimeout = 5 * time.Second
+
+// SyslogFormat is the format of the messages written by a SyslogWriter.
+type SyslogFormat int
+
+const (
+	// RFC5424 formats messages as described by RFC 5424.
+	RFC5424 SyslogFormat = iota
+
+	// RFC3164 formats messages in the legacy BSD format described by RFC 3164.
+	RFC3164
+)
+
+// SyslogFraming defines how messages are separated on stream connections.
+type SyslogFraming int
+
+const (
+	// DefaultFraming uses OctetCounting on TCP and unix stream sockets, and
+	// NoFraming on UDP and unix datagram sockets.
+	DefaultFraming SyslogFraming = iota
+
+	// OctetCounting prefixes each message with its length, as described by
+	// RFC 6587.
+	OctetCounting
+
+	// NonTransparentFraming terminates each message with a newline.
+	NonTransparentFraming
+
+	// NoFraming writes each message as it is, which is only suitable for
+	// datagram sockets.
+	NoFraming
+)
+
+// Facility is a syslog facility.
+type Facility int
+
+// The syslog facilities.
+const (
+	FacilityKern Facility = iota
+	FacilityUser
+	FacilityMail
+	FacilityDaemon
+	FacilityAuth
+	FacilitySyslog
+	FacilityLPR
+	FacilityNews
+	FacilityUUCP
+	FacilityCron
+	FacilityAuthPriv
+	FacilityFTP
+	FacilityNTP
+	FacilityAudit
+	FacilityAlert
+	FacilityClock
+	FacilityLocal0
+	FacilityLocal1
+	FacilityLocal2
+	FacilityLocal3
+	FacilityLocal4
+	FacilityLocal5
+	FacilityLocal6
+	FacilityLocal7
+)
+
+// syslogSockets are the paths searched for the local syslog socket.
+var syslogSockets = []string{"/dev/log", "/var/run/syslog", "/var/run/log"}
+
+// SyslogWriter is an implementation of LevelWriter which writes each message
+// to a syslog server, using the severity matching the level of the message.
+// DebugLevel through EmergencyLevel map to the severities 7 through 0.
+//
+// The connection is made by Open, or by the first write, and it's made again
+// when a write fails. The writer is safe for concurrent use, but its fields
+// must be set before it's used.
+type SyslogWriter struct {
+	// Network is the network of the server: "udp", "tcp", "unix" or
+	// "unixgram". The local syslog socket is used when empty.
+	Network string
+
+	// Addr is the address of the server.
+	Addr string
+
+	// Format is the format of the messages.
+	Format SyslogFormat
+
+	// Framing defines how messages are separated on stream connections.
+	Framing SyslogFraming
+
+	// Facility is the facility of the messages.
+	Facility Facility
+
+	// Hostname identifies the machine sending the messages.
+	Hostname string
+
+	// AppName identifies the application sending the messages. It's used as
+	// the tag of RFC 3164 messages.
+	AppName string
+
+	// ProcID identifies the process sending the messages.
+	ProcID string
+
+	// MsgID identifies the type of the messages. It's only used by RFC 5424.
+	MsgID string
+
+	// Timeout is the time to wait to connect, or to write a message. There is
+	// no timeout when zero.
+	Timeout time.Duration
+
+	// mu guards the connection.
+	mu sync.Mutex
+
+	// conn is the connection to the server, or nil when not connected.
+	conn net.Conn
+
+	// stream defines whether the connection is a stream.
+	stream bool
+
+	// closed defines whether the writer has been closed.
+	closed bool
+
+	// now returns the current time, and may be replaced by tests.
+	now func() time.Time
+}
+
+// NewSyslogWriter creates and returns a new *SyslogWriter instance which
+// writes RFC 5424 messages to the server, using the user facility. The local
+// syslog socket is used when network and addr are empty. The hostname,
+// application name and process ID default to those of the running process.
+func NewSyslogWriter(network, addr string) *SyslogWriter {
+	hostname, _ := os.Hostname()
+	return &SyslogWriter{
+		Network:  network,
+		Addr:     addr,
+		Format:   RFC5424,
+		Facility: FacilityUser,
+		Hostname: hostname,
+		AppName:  filepath.Base(os.Args[0]),
+		ProcID:   strconv.Itoa(os.Getpid()),
+		Timeout:  DefaultSyslogTimeout,
+		now:      time.Now,
+	}
+}
+
+// Open connects to the server.
+func (w *SyslogWriter) Open() error {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	if w.closed {
+		return errors.New("xlog: syslog writer is closed")
+	}
+	if w.conn != nil {
+		return nil
+	}
+
+	return w.connect()
+}
+
+// Name returns the address of the server.
+func (w *SyslogWriter) Name() string {
+	if w.Network == "" {
+		return "syslog"
+	}
+
+	return fmt.Sprintf("syslog+%s://%s", w.Network, w.Addr)
+}
+
+// Write implements io.Writer.Write by writing the message at InfoLevel.
+func (w *SyslogWriter) Write(p []byte) (int, error) {
+	return w.WriteLevel(InfoLevel, p)
+}
+
+// WriteLevel writes the message with the severity matching the level. A write
+// which fails is retried once on a new connection.
+func (w *SyslogWriter) WriteLevel(level Level, p []byte) (int, error) {
+	msg := w.format(level, bytes.TrimRight(p, "\n"))
+
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	if w.closed {
+		return 0, errors.New("xlog: syslog writer is closed")
+	}
+	for attempt := 0; ; attempt++ {
+		err := w.write(msg)
+		if err == nil {
+			return len(p), nil
+		}
+		if w.conn != nil {
+			w.conn.Close()
+			w.conn = nil
+		}
+		if attempt > 0 {
+			return 0, err
+		}
+	}
+}
+
+// Close closes the connection to the server.
+func (w *SyslogWriter) Close() error {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	w.closed = true
+	if w.conn == nil {
+		return nil
+	}
+	err := w.conn.Close()
+	w.conn = nil
+
+	return err
+}
+
+// write writes the formatted message, connecting to the server when needed.
+// It must be called with w.mu held.
+func (w *SyslogWriter) write(msg []byte) error {
+	if w.conn == nil {
+		if err := w.connect(); err != nil {
+			return err
+		}
+	}
+	if w.Timeout > 0 {
+		w.conn.SetWriteDeadline(time.Now().Add(w.Timeout))
+	}
+
+	framing := w.Framing
+	if framing == DefaultFraming {
+		framing = NoFraming
+		if w.stream {
+			framing = OctetCounting
+		}
+	}
+	switch framing {
+	case OctetCounting:
+		msg = append([]byte(strconv.Itoa(len(msg))+" "), msg...)
+	case NonTransparentFraming:
+		msg = append(msg, '\n')
+	}
+	_, err := w.conn.Write(msg)
+
+	return err
+}
+
+// connect connects to the server. It must be called with w.mu held.
+func (w *SyslogWriter) connect() error {
+	dialer := net.Dialer{Timeout: w.Timeout}
+	if w.Network != "" {
+		conn, err := dialer.Dial(w.Network, w.Addr)
+		if err != nil {
+			return err
+		}
+		w.conn, w.stream = conn, !strings.HasPrefix(w.Network, "udp") && w.Network != "unixgram"
+		return nil
+	}
+
+	for _, path := range syslogSockets {
+		for _, network := range []string{"unixgram", "unix"} {
+			if conn, err := dialer.Dial(network, path); err == nil {
+				w.conn, w.stream = conn, network == "unix"
+				return nil
+			}
+		}
+	}
+
+	return errors.New("xlog: unable to connect to the local syslog socket")
+}
+
+// format returns the message formatted for the server.
+func (w *SyslogWriter) format(level Level, msg []byte) []byte {
+	now := time.Now
+	if w.now != nil {
+		now = w.now
+	}
+	t := now()
+	pri := int(w.Facility)*8 + syslogSeverity(level)
+
+	var buf bytes.Buffer
+	if w.Format == RFC3164 {
+		tag := syslogField(w.AppName, 32)
+		if w.ProcID != "" {
+			tag += "[" + syslogField(w.ProcID, 128) + "]"
+		}
+		fmt.Fprintf(&buf, "<%d>%s %s %s: ", pri, t.Format(time.Stamp), syslogField(w.Hostname, 255), tag)
+	} else {
+		fmt.Fprintf(&buf, "<%d>1 %s %s %s %s %s - ",
+			pri,
+			t.Format("2006-01-02T15:04:05.000000Z07:00"),
+			syslogField(w.Hostname, 255),
+			syslogField(w.AppName, 48),
+			syslogField(w.ProcID, 128),
+			syslogField(w.MsgID, 32),
+		)
+	}
+	buf.Write(msg)
+
+	return buf.Bytes()
+}
+
+// syslogSeverity returns the syslog severity matching the level. The highest
+// level is used when the level contains several levels.
+func syslogSeverity(level Level) int {
+	for i := len(levelOrder) - 1; i >= 0; i-- {
+		if level&levelOrder[i] > 0 {
+			return len(levelOrder) - 1 - i
+		}
+	}
+
+	return 7
+}
+
+// syslogField returns the value as a header field, which is made of at most
+// max printable ASCII characters other than space, or "-" when empty.
+func syslogField(value string, max int) string {
+	field := strings.Map(func(r rune) rune {
+		if r < 33 || r > 126 {
+			return '_'
+		}
+		return r
+	}, value)
+	if len(field) > max {
+		field = field[:max]
+	}
+	if field == "" {
+		return "-"
+	}
+
+	return field
+}
@@ -0,0 +1,140 @@
+package xlog
+
+import (
+	"bufio"
+	"net"
+	"path/filepath"
+	"regexp"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+)
+
+// SyslogTime returns a fixed time for syslog messages.
+func SyslogTime() time.Time {
+	return time.Date(2014, 11, 15, 9, 40, 28, 693000000, time.UTC)
+}
+
+// TestSyslogUDP -
+func TestSyslogUDP(t *testing.T) {
+	conn, err := net.ListenPacket("udp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer conn.Close()
+
+	w := NewSyslogWriter("udp", conn.LocalAddr().String())
+	w.Facility = FacilityLocal0
+	w.Hostname = "host"
+	w.AppName = "app"
+	w.ProcID = "42"
+	w.MsgID = "ID47"
+	w.now = SyslogTime
+	defer w.Close()
+
+	logger := New(LoggerName)
+	logger.Formatter = NewDefaultFormatter("{message}", DefaultDateFormat)
+	logger.AppendFile(w, DebugLevel)
+
+	buf := make([]byte, 1024)
+	for level, expected := range map[Level]int{DebugLevel: 135, WarningLevel: 132, EmergencyLevel: 128} {
+		logger.Log(level, "This is a test.")
+		conn.SetReadDeadline(time.Now().Add(5 * time.Second))
+		n, _, err := conn.ReadFrom(buf)
+		if err != nil {
+			t.Fatal(err)
+		}
+		ActualEquals(t, string(buf[:n]), "<"+strconv.Itoa(expected)+">1 2014-11-15T09:40:28.693000Z host app 42 ID47 - This is a test.")
+	}
+}
+
+// TestSyslogUnixgram -
+func TestSyslogUnixgram(t *testing.T) {
+	name := filepath.Join(t.TempDir(), "log.sock")
+	conn, err := net.ListenPacket("unixgram", name)
+	if err != nil {
+		t.Skip(err)
+	}
+	defer conn.Close()
+
+	w := NewSyslogWriter("unixgram", name)
+	w.Format = RFC3164
+	w.Hostname = "host"
+	w.AppName = "my app"
+	w.ProcID = "42"
+	w.now = SyslogTime
+	defer w.Close()
+	w.WriteLevel(ErrorLevel, []byte("This is a test.\n"))
+
+	buf := make([]byte, 1024)
+	conn.SetReadDeadline(time.Now().Add(5 * time.Second))
+	n, _, err := conn.ReadFrom(buf)
+	if err != nil {
+		t.Fatal(err)
+	}
+	ActualEquals(t, string(buf[:n]), "<11>Nov 15 09:40:28 host my_app[42]: This is a test.")
+}
+
+// TestSyslogTCP -
+func TestSyslogTCP(t *testing.T) {
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer ln.Close()
+	messages := make(chan string, 16)
+	go func() {
+		for {
+			conn, err := ln.Accept()
+			if err != nil {
+				return
+			}
+			r := bufio.NewReader(conn)
+			length, err := r.ReadString(' ')
+			if err == nil {
+				n, _ := strconv.Atoi(strings.TrimSpace(length))
+				msg := make([]byte, n)
+				if _, err := r.Read(msg); err == nil {
+					messages <- string(msg)
+				}
+			}
+			// Only one message is read from each connection, so the writer
+			// has to reconnect for the next message.
+			conn.Close()
+		}
+	}()
+
+	w := NewSyslogWriter("tcp", ln.Addr().String())
+	w.now = SyslogTime
+	defer w.Close()
+	if err := w.Open(); err != nil {
+		t.Fatal(err)
+	}
+
+	pattern := regexp.MustCompile(`^<11>1 2014-11-15T09:40:28.693000Z \S+ \S+ \d+ - - Message \d\.$`)
+	received := 0
+	for i := 0; i < 20 && received < 2; i++ {
+		w.WriteLevel(ErrorLevel, []byte("Message "+strconv.Itoa(i%10)+".\n"))
+		select {
+		case msg := <-messages:
+			if !pattern.MatchString(msg) {
+				t.Errorf("Unexpected message '%s'.", msg)
+			}
+			received++
+		case <-time.After(50 * time.Millisecond):
+		}
+	}
+	if received < 2 {
+		t.Errorf("Expected the writer to reconnect, but received %d messages.", received)
+	}
+}
+
+// TestSyslogSeverity -
+func TestSyslogSeverity(t *testing.T) {
+	for i, level := range levelOrder {
+		if severity := syslogSeverity(level); severity != 7-i {
+			t.Errorf("Expected the severity %d for %s but got %d.", 7-i, Levels[level], severity)
+		}
+	}
+}
@@ -0,0 +1,194 @@
+package xlog
+
+import (
+	"bytes"
+	"fmt"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"text/template"
+	"time"
+)
+
+// templateFuncs are the functions which can be used in the templates of a
+// TemplateFormatter.
+var templateFuncs = template.FuncMap{
+	"json": func(value interface{}) string {
+		buf := &bytes.Buffer{}
+		writeJSON(buf, value)
+		return buf.String()
+	},
+	"pad": func(width int, value interface{}) string {
+		return pad(fmt.Sprint(value), width)
+	},
+	"upper": func(value interface{}) string {
+		return strings.ToUpper(fmt.Sprint(value))
+	},
+	"lower": func(value interface{}) string {
+		return strings.ToLower(fmt.Sprint(value))
+	},
+	"date": func(layout string, t time.Time) string {
+		return string(appendDate(nil, t, layout))
+	},
+}
+
+// TemplateFormatter is an implementation of the Formatter interface which
+// formats each message using a text/template template. The template is
+// executed with the Entry of the message, so it may use .Time, .Level, .Name,
+// .Message, .Args, .Fields, .Caller and .Stack, and the values of the
+// placeholders added with PlaceholderFunc, e.g. {{.Placeholder "hostname"}}.
+// The .Level is the name of the level, e.g. "WARNING".
+// The caller is only looked up when the template uses .Caller, and the stack
+// is only captured when it uses .Stack.
+//
+// Besides the functions of text/template, the template may use:
+//
+//	json    encodes the value as JSON, e.g. {{json .Message}}
+//	pad     pads the value with spaces, aligned left when the width is negative, e.g. {{pad -9 .Level}}
+//	upper   converts the value to upper case
+//	lower   converts the value to lower case
+//	date    formats a time using a layout or a date preset, e.g. {{date "rfc3339" .Time}}
+//
+// Messages which cannot be formatted using the template are formatted using
+// DefaultMessageFormat, so they are not lost. The formatter is safe for
+// concurrent use, but ErrorFunc must be set before it's used.
+type TemplateFormatter struct {
+	// ErrorFunc is called with the errors of SetFormat, and the errors which
+	// happen while formatting messages. Errors are ignored when nil.
+	ErrorFunc func(error)
+
+	// mu serializes changes to the template.
+	mu sync.Mutex
+
+	// format stores the *templateFormat being used.
+	format atomic.Value
+
+	// funcs provide the values of the placeholders.
+	funcs placeholderFuncs
+
+	// fallback formats the messages which cannot be formatted using the template.
+	fallback *DefaultFormatter
+}
+
+// templateFormat holds the compiled template of a TemplateFormatter.
+type templateFormat struct {
+	// tmpl is the compiled template.
+	tmpl *template.Template
+
+	// caller defines whether the template uses the caller.
+	caller bool
+
+	// stack defines whether the template uses the stack.
+	stack bool
+}
+
+// templateEntry is the value templates are executed with.
+type templateEntry struct {
+	*Entry
+
+	// Level is the name of the level, which templates use rather than the
+	// level of the entry.
+	Level string
+
+	// funcs provide the values of the placeholders.
+	funcs funcMap
+}
+
+// Placeholder returns the value of the placeholder added with PlaceholderFunc
+// or PlaceholderEntryFunc, or nil when there is none.
+func (e templateEntry) Placeholder(key string) interface{} {
+	if fn, ok := e.funcs.funcs[key]; ok {
+		return fn(e.Entry)
+	}
+
+	return nil
+}
+
+// NewTemplateFormatter creates and returns a new *TemplateFormatter instance
+// which formats messages using the template, e.g.
+// `{{date "15:04:05" .Time}} {{pad -9 .Level}} {{.Message}}`. An error is
+// returned when the template cannot be compiled.
+func NewTemplateFormatter(format string) (*TemplateFormatter, error) {
+	f := &TemplateFormatter{
+		fallback: NewDefaultFormatter(DefaultMessageFormat, DefaultDateFormat),
+	}
+	if err := f.Parse(format); err != nil {
+		return nil, err
+	}
+
+	return f, nil
+}
+
+// SetFormat changes the template. The template in use is kept when the new
+// template cannot be compiled, and the error is passed to ErrorFunc.
+func (f *TemplateFormatter) SetFormat(format string) {
+	if err := f.Parse(format); err != nil && f.ErrorFunc != nil {
+		f.ErrorFunc(err)
+	}
+}
+
+// Parse compiles the template, and uses it to format messages. The template
+// in use is kept when an error is returned.
+func (f *TemplateFormatter) Parse(format string) error {
+	tmpl, err := template.New("xlog").Funcs(templateFuncs).Parse(format)
+	if err != nil {
+		return fmt.Errorf("xlog: %v", err)
+	}
+
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	f.format.Store(&templateFormat{
+		tmpl:   tmpl,
+		caller: strings.Contains(format, ".Caller"),
+		stack:  strings.Contains(format, ".Stack"),
+	})
+
+	return nil
+}
+
+// PlaceholderFunc adds a callback function which provides the value of
+// {{.Placeholder key}}.
+func (f *TemplateFormatter) PlaceholderFunc(key string, fn func(string) string) {
+	f.funcs.add(key, fn)
+}
+
+// PlaceholderEntryFunc adds a callback function which provides the value of
+// {{.Placeholder key}}, and is given the entry being formatted. The entry
+// must not be modified.
+func (f *TemplateFormatter) PlaceholderEntryFunc(key string, fn func(entry *Entry) interface{}) {
+	f.funcs.addEntry(key, fn)
+}
+
+// Format formats a log message for the given level.
+func (f *TemplateFormatter) Format(name string, level Level, fields Fields, v ...interface{}) string {
+	return f.FormatEntry(argsEntry(name, level, fields, v))
+}
+
+// UsesCaller returns whether the template uses the caller.
+func (f *TemplateFormatter) UsesCaller() bool {
+	return f.load().caller
+}
+
+// UsesStack returns whether the template uses the stack.
+func (f *TemplateFormatter) UsesStack() bool {
+	return f.load().stack
+}
+
+// FormatEntry formats the entry.
+func (f *TemplateFormatter) FormatEntry(entry *Entry) string {
+	buf := &bytes.Buffer{}
+	err := f.load().tmpl.Execute(buf, templateEntry{entry, Levels[entry.Level], f.funcs.load()})
+	if err != nil {
+		if f.ErrorFunc != nil {
+			f.ErrorFunc(fmt.Errorf("xlog: %v", err))
+		}
+		return f.fallback.FormatEntry(entry)
+	}
+
+	return buf.String()
+}
+
+// load returns the template being used.
+func (f *TemplateFormatter) load() *templateFormat {
+	return f.format.Load().(*templateFormat)
+}
@@ -0,0 +1,56 @@
+package xlog
+
+import (
+	"testing"
+	"time"
+)
+
+// TestTemplateFormatter -
+func TestTemplateFormatter(t *testing.T) {
+	formatter, err := NewTemplateFormatter(`{{date "15:04:05" .Time}} {{pad -7 .Level}} {{.Name | upper}} {{json .Message}}` +
+		`{{range $key, $value := .Fields}} {{$key}}={{$value}}{{end}}{{with .Placeholder "host"}} host={{.}}{{end}}`)
+	if err != nil {
+		t.Fatal(err)
+	}
+	formatter.PlaceholderFunc("host", func(key string) string {
+		return "web-1"
+	})
+	entry := &Entry{
+		Time:    time.Date(2014, 11, 15, 9, 40, 28, 0, time.UTC),
+		Level:   InfoLevel,
+		Name:    "api",
+		Message: `Say "hi".`,
+		Fields:  Fields{"user": "sean", "id": 42},
+	}
+	ActualEquals(t, formatter.FormatEntry(entry), `09:40:28 INFO    API "Say \"hi\"." id=42 user=sean host=web-1`)
+	if formatter.UsesCaller() || formatter.UsesStack() {
+		t.Error("Expected the template to not use the caller or the stack.")
+	}
+
+	var errs []error
+	formatter.ErrorFunc = func(err error) {
+		errs = append(errs, err)
+	}
+	formatter.SetFormat("{{.Message")
+	ActualEquals(t, formatter.FormatEntry(entry)[:8], "09:40:28")
+	formatter.SetFormat("{{.Missing}}")
+	ActualEquals(t, formatter.FormatEntry(entry), "2014-11-15 09:40:28.000 api.INFO Say \"hi\".")
+	if len(errs) != 2 {
+		t.Fatalf("Expected 2 errors but got %v.", errs)
+	}
+	if _, err := NewTemplateFormatter("{{if}}"); err == nil {
+		t.Error("Expected an error for an invalid template.")
+	}
+}
+
+// TestTemplateFormatterLogger -
+func TestTemplateFormatterLogger(t *testing.T) {
+	formatter, err := NewTemplateFormatter(`{{.Level}}{{with .Caller}} {{.ShortFile}}{{end}} {{.Message}}`)
+	if err != nil {
+		t.Fatal(err)
+	}
+	logger, writer := LoggerFixture(DebugLevel)
+	logger.Formatter = formatter
+	logger.Warning("Test.")
+	ActualEquals(t, writer.String(), "WARNING template_test.go Test.\n")
+}
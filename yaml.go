@@ -0,0 +1,335 @@
+package xlog
+
+import (
+	"bytes"
+	"fmt"
+	"strconv"
+	"strings"
+)
+
+// yamlLine is a line of a YAML document without its indentation and comment.
+type yamlLine struct {
+	num    int
+	indent int
+	text   string
+}
+
+// yamlParser parses the subset of YAML used by configuration files: block
+// mappings and sequences, plain and quoted scalars, flow sequences of scalars,
+// and comments. Anchors, tags, multi-line scalars and flow mappings are not
+// supported.
+type yamlParser struct {
+	lines []yamlLine
+	pos   int
+}
+
+// parseYAMLNode parses a YAML document.
+func parseYAMLNode(data []byte) (*configNode, error) {
+	p := &yamlParser{}
+	for i, raw := range bytes.Split(data, []byte("\n")) {
+		num := i + 1
+		text := strings.TrimRight(string(raw), " \t\r")
+		if i == 0 {
+			text = strings.TrimPrefix(text, "\ufeff")
+		}
+		trimmed := strings.TrimLeft(text, " ")
+		if trimmed == "" || trimmed[0] == '#' {
+			continue
+		}
+		if trimmed[0] == '\t' {
+			return nil, &ConfigError{Line: num, Err: fmt.Errorf("tabs cannot be used for indentation")}
+		}
+		if trimmed == "---" && len(p.lines) == 0 {
+			continue
+		}
+		if trimmed == "..." || trimmed == "---" {
+			if trimmed == "---" {
+				return nil, &ConfigError{Line: num, Err: fmt.Errorf("multiple documents are not supported")}
+			}
+			break
+		}
+		indent := len(text) - len(trimmed)
+		p.lines = append(p.lines, yamlLine{num, indent, stripYAMLComment(trimmed)})
+	}
+
+	if len(p.lines) == 0 {
+		return &configNode{line: 1, kind: nullNode}, nil
+	}
+	node, err := p.parseBlock(p.lines[0].indent)
+	if err != nil {
+		return nil, err
+	}
+	if p.pos < len(p.lines) {
+		return nil, p.errorf(p.lines[p.pos], "unexpected indentation")
+	}
+
+	return node, nil
+}
+
+// parseBlock parses the mapping or sequence starting at the current line.
+func (p *yamlParser) parseBlock(indent int) (*configNode, error) {
+	if isYAMLSequenceItem(p.lines[p.pos].text) {
+		return p.parseSequence(indent)
+	}
+
+	return p.parseMapping(indent)
+}
+
+// parseMapping parses the keys of a mapping at the given indentation.
+func (p *yamlParser) parseMapping(indent int) (*configNode, error) {
+	node := newMappingNode(p.lines[p.pos].num)
+	for p.pos < len(p.lines) {
+		line := p.lines[p.pos]
+		if line.indent < indent {
+			break
+		}
+		if line.indent > indent {
+			return nil, p.errorf(line, "unexpected indentation")
+		}
+		if isYAMLSequenceItem(line.text) {
+			return nil, p.errorf(line, "expected a key, but found a list item")
+		}
+
+		key, rest, err := splitYAMLKey(line.text)
+		if err != nil {
+			return nil, p.errorf(line, "%v", err)
+		}
+		p.pos++
+		var value *configNode
+		if rest != "" {
+			value, err = parseYAMLScalar(rest, line.num)
+		} else {
+			value, err = p.parseNested(indent, line.num, true)
+		}
+		if err != nil {
+			return nil, err
+		}
+		if err := node.set(key, value); err != nil {
+			return nil, p.errorf(line, "duplicate key %q", key)
+		}
+	}
+
+	return node, nil
+}
+
+// parseSequence parses the items of a sequence at the given indentation.
+func (p *yamlParser) parseSequence(indent int) (*configNode, error) {
+	node := &configNode{line: p.lines[p.pos].num, kind: sequenceNode}
+	for p.pos < len(p.lines) {
+		line := p.lines[p.pos]
+		if line.indent < indent || (line.indent == indent && !isYAMLSequenceItem(line.text)) {
+			break
+		}
+		if line.indent > indent {
+			return nil, p.errorf(line, "unexpected indentation")
+		}
+
+		content := strings.TrimLeft(line.text[1:], " ")
+		var item *configNode
+		var err error
+		switch {
+		case content == "":
+			p.pos++
+			item, err = p.parseNested(indent, line.num, false)
+		case isYAMLSequenceItem(content) || isYAMLMappingEntry(content):
+			// The item is a block starting on the same line as the dash, so
+			// the line is replaced by its content at the content's column.
+			p.lines[p.pos] = yamlLine{line.num, indent + len(line.text) - len(content), content}
+			item, err = p.parseBlock(p.lines[p.pos].indent)
+		default:
+			p.pos++
+			item, err = parseYAMLScalar(content, line.num)
+		}
+		if err != nil {
+			return nil, err
+		}
+		node.items = append(node.items, item)
+	}
+
+	return node, nil
+}
+
+// parseNested parses the value of a key or list item which starts on the next
+// line. A sequence may be at the same indentation as the key of a mapping.
+func (p *yamlParser) parseNested(indent, num int, key bool) (*configNode, error) {
+	if p.pos < len(p.lines) {
+		next := p.lines[p.pos]
+		if next.indent > indent {
+			return p.parseBlock(next.indent)
+		}
+		if key && next.indent == indent && isYAMLSequenceItem(next.text) {
+			return p.parseSequence(indent)
+		}
+	}
+
+	return &configNode{line: num, kind: nullNode}, nil
+}
+
+// errorf returns a *ConfigError for the line.
+func (p *yamlParser) errorf(line yamlLine, format string, v ...interface{}) error {
+	return &ConfigError{Line: line.num, Err: fmt.Errorf(format, v...)}
+}
+
+// isYAMLSequenceItem returns whether the text is a list item.
+func isYAMLSequenceItem(text string) bool {
+	return text == "-" || strings.HasPrefix(text, "- ")
+}
+
+// isYAMLMappingEntry returns whether the text is a key followed by a value.
+func isYAMLMappingEntry(text string) bool {
+	_, _, err := splitYAMLKey(text)
+	return err == nil
+}
+
+// splitYAMLKey splits a "key: value" line into the key and the value.
+func splitYAMLKey(text string) (string, string, error) {
+	if text[0] == '"' || text[0] == '\'' {
+		end := yamlQuoteEnd(text)
+		if end < 0 {
+			return "", "", fmt.Errorf("unterminated quoted key")
+		}
+		rest := strings.TrimLeft(text[end+1:], " ")
+		if !strings.HasPrefix(rest, ":") || (len(rest) > 1 && rest[1] != ' ') {
+			return "", "", fmt.Errorf("expected a colon after the key")
+		}
+		key, err := parseYAMLScalar(text[:end+1], 0)
+		if err != nil {
+			return "", "", err
+		}
+		return key.value, strings.TrimSpace(rest[1:]), nil
+	}
+
+	for i := 0; i < len(text); i++ {
+		if text[i] == ':' && (i == len(text)-1 || text[i+1] == ' ') {
+			key := strings.TrimSpace(text[:i])
+			if key == "" {
+				break
+			}
+			return key, strings.TrimSpace(text[i+1:]), nil
+		}
+	}
+
+	return "", "", fmt.Errorf("expected a key followed by a colon")
+}
+
+// parseYAMLScalar parses a scalar or a flow sequence of scalars.
+func parseYAMLScalar(text string, num int) (*configNode, error) {
+	errorf := func(format string, v ...interface{}) error {
+		return &ConfigError{Line: num, Err: fmt.Errorf(format, v...)}
+	}
+
+	switch text[0] {
+	case '"', '\'':
+		end := yamlQuoteEnd(text)
+		if end < 0 {
+			return nil, errorf("unterminated quoted string")
+		}
+		if end != len(text)-1 {
+			return nil, errorf("unexpected text after quoted string")
+		}
+		if text[0] == '\'' {
+			value := strings.Replace(text[1:end], "''", "'", -1)
+			return &configNode{line: num, kind: scalarNode, value: value, quoted: true}, nil
+		}
+		value, err := strconv.Unquote(text)
+		if err != nil {
+			return nil, errorf("invalid quoted string %s", text)
+		}
+		return &configNode{line: num, kind: scalarNode, value: value, quoted: true}, nil
+	case '[':
+		if text[len(text)-1] != ']' {
+			return nil, errorf("unterminated list")
+		}
+		node := &configNode{line: num, kind: sequenceNode}
+		inner := strings.TrimSpace(text[1 : len(text)-1])
+		for inner != "" {
+			item := inner
+			if inner[0] == '"' || inner[0] == '\'' {
+				end := yamlQuoteEnd(inner)
+				if end < 0 {
+					return nil, errorf("unterminated quoted string")
+				}
+				item = inner[:end+1]
+			} else if i := strings.IndexByte(inner, ','); i >= 0 {
+				item = inner[:i]
+			}
+			if strings.TrimSpace(item) == "" {
+				return nil, errorf("empty list item")
+			}
+			value, err := parseYAMLScalar(strings.TrimSpace(item), num)
+			if err != nil {
+				return nil, err
+			}
+			if value.kind == sequenceNode {
+				return nil, errorf("nested lists are not supported")
+			}
+			node.items = append(node.items, value)
+
+			inner = strings.TrimSpace(inner[len(item):])
+			if inner != "" {
+				if inner[0] != ',' {
+					return nil, errorf("expected a comma between list items")
+				}
+				inner = strings.TrimSpace(inner[1:])
+			}
+		}
+		return node, nil
+	case '{':
+		if text == "{}" {
+			return newMappingNode(num), nil
+		}
+		return nil, errorf("flow mappings are not supported, quote the value if it's a string")
+	case '&', '*', '!', '|', '>', '%', '@', '`':
+		return nil, errorf("unsupported YAML syntax %q, quote the value if it's a string", text[:1])
+	}
+
+	switch text {
+	case "~", "null", "Null", "NULL":
+		return &configNode{line: num, kind: nullNode}, nil
+	}
+
+	return &configNode{line: num, kind: scalarNode, value: text}, nil
+}
+
+// yamlQuoteEnd returns the index of the quote closing the string at the start
+// of the text, or -1 when the string is not closed.
+func yamlQuoteEnd(text string) int {
+	quote := text[0]
+	for i := 1; i < len(text); i++ {
+		switch {
+		case quote == '"' && text[i] == '\\':
+			i++
+		case text[i] == quote && quote == '\'' && i+1 < len(text) && text[i+1] == '\'':
+			i++
+		case text[i] == quote:
+			return i
+		}
+	}
+
+	return -1
+}
+
+// stripYAMLComment removes a comment from the end of the line, ignoring "#"
+// characters inside quoted strings or not preceded by a space.
+func stripYAMLComment(text string) string {
+	var quote byte
+	for i := 0; i < len(text); i++ {
+		c := text[i]
+		switch {
+		case quote == '"' && c == '\\':
+			i++
+		case quote == '\'' && c == '\'' && i+1 < len(text) && text[i+1] == '\'':
+			i++
+		case quote != 0:
+			if c == quote {
+				quote = 0
+			}
+		case (c == '"' || c == '\'') && (i == 0 || strings.IndexByte(" [,", text[i-1]) >= 0):
+			quote = c
+		case c == '#' && i > 0 && text[i-1] == ' ':
+			return strings.TrimRight(text[:i], " ")
+		}
+	}
+
+	return text
+}